@@ -0,0 +1,388 @@
+// Package corecursive содержит общие для примеров обобщённые помощники,
+// написанные в том же стиле: один элемент за шаг и аккумулятор вместо цикла.
+package corecursive
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var ErrNonPositiveSize = errors.New("size must be positive")
+
+// FoldUntil сворачивает items, пока step не вернёт stop=true,
+// и возвращает аккумулятор на момент остановки
+func FoldUntil[T, A any](items []T, init A, step func(A, T) (A, bool)) A {
+	if len(items) == 0 {
+		return init
+	}
+
+	acc, stop := step(init, items[0])
+	if stop {
+		return acc
+	}
+	return FoldUntil(items[1:], acc, step)
+}
+
+// Zip попарно объединяет as и bs через combine, длина результата - по более короткому срезу
+func Zip[A, B, C any](as []A, bs []B, combine func(A, B) C) []C {
+	return zip(as, bs, combine, make([]C, 0, min(len(as), len(bs))))
+}
+
+func zip[A, B, C any](as []A, bs []B, combine func(A, B) C, acc []C) []C {
+	if len(as) == 0 || len(bs) == 0 {
+		return acc
+	}
+	return zip(as[1:], bs[1:], combine, append(acc, combine(as[0], bs[0])))
+}
+
+// Dedupe убирает повторы, сохраняя порядок первых вхождений
+func Dedupe[T comparable](items []T) []T {
+	return dedupe(items, make(map[T]struct{}, len(items)), make([]T, 0, len(items)))
+}
+
+func dedupe[T comparable](remaining []T, seen map[T]struct{}, acc []T) []T {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	if _, ok := seen[remaining[0]]; !ok {
+		seen[remaining[0]] = struct{}{}
+		acc = append(acc, remaining[0])
+	}
+	return dedupe(remaining[1:], seen, acc)
+}
+
+// Chunk делит items на части не длиннее size, последняя может быть короче.
+// size <= 0 - ошибка программиста, поэтому паника, а не error
+func Chunk[T any](items []T, size int) [][]T {
+	if size <= 0 {
+		panic("corecursive: Chunk size must be positive")
+	}
+	return chunk(items, size, make([][]T, 0, (len(items)+size-1)/size))
+}
+
+func chunk[T any](remaining []T, size int, acc [][]T) [][]T {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	n := min(size, len(remaining))
+	return chunk(remaining[n:], size, append(acc, remaining[:n:n]))
+}
+
+// Reverse возвращает новый срез в обратном порядке, исходный не меняется
+func Reverse[T any](items []T) []T {
+	return reverse(items, make([]T, 0, len(items)))
+}
+
+func reverse[T any](remaining []T, acc []T) []T {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return reverse(remaining[:len(remaining)-1], append(acc, remaining[len(remaining)-1]))
+}
+
+// Count считает элементы, для которых pred истинен
+func Count[T any](items []T, pred func(T) bool) int {
+	return count(items, pred, 0)
+}
+
+func count[T any](remaining []T, pred func(T) bool, acc int) int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	if pred(remaining[0]) {
+		acc++
+	}
+	return count(remaining[1:], pred, acc)
+}
+
+// MaxBy возвращает элемент с наибольшим value, при равенстве - первый встреченный.
+// Для пустого среза второй результат false
+func MaxBy[T any](items []T, value func(T) int) (T, bool) {
+	return bestBy(items, value, func(a, b int) bool { return a > b })
+}
+
+// MinBy возвращает элемент с наименьшим value, при равенстве - первый встреченный.
+// Для пустого среза второй результат false
+func MinBy[T any](items []T, value func(T) int) (T, bool) {
+	return bestBy(items, value, func(a, b int) bool { return a < b })
+}
+
+func bestBy[T any](items []T, value func(T) int, better func(a, b int) bool) (T, bool) {
+	if len(items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return best(items[1:], value, better, items[0]), true
+}
+
+func best[T any](remaining []T, value func(T) int, better func(a, b int) bool, acc T) T {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	if better(value(remaining[0]), value(acc)) {
+		acc = remaining[0]
+	}
+	return best(remaining[1:], value, better, acc)
+}
+
+// FlatMap склеивает результаты f для всех элементов по порядку
+func FlatMap[T, U any](items []T, f func(T) []U) []U {
+	return Concat(mapParts(items, f, make([][]U, 0, len(items)))...)
+}
+
+func mapParts[T, U any](remaining []T, f func(T) []U, acc [][]U) [][]U {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return mapParts(remaining[1:], f, append(acc, f(remaining[0])))
+}
+
+// Concat склеивает срезы по порядку в новый срез, выделенный сразу нужного размера
+func Concat[T any](slices ...[]T) []T {
+	return concat(slices, make([]T, 0, totalLen(slices, 0)))
+}
+
+func totalLen[T any](remaining [][]T, acc int) int {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return totalLen(remaining[1:], acc+len(remaining[0]))
+}
+
+func concat[T any](remaining [][]T, acc []T) []T {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return concat(remaining[1:], append(acc, remaining[0]...))
+}
+
+// Any истинен, если pred выполняется хотя бы для одного элемента; для пустого среза - false.
+// Обход останавливается на первом совпадении
+func Any[T any](items []T, pred func(T) bool) bool {
+	if len(items) == 0 {
+		return false
+	}
+	return pred(items[0]) || Any(items[1:], pred)
+}
+
+// All истинен, если pred выполняется для всех элементов; для пустого среза - true.
+// Обход останавливается на первом несовпадении
+func All[T any](items []T, pred func(T) bool) bool {
+	if len(items) == 0 {
+		return true
+	}
+	return pred(items[0]) && All(items[1:], pred)
+}
+
+// SlidingWindow возвращает все подряд идущие окна длины size, их len(items)-size+1.
+// Если size больше длины, окон нет
+func SlidingWindow[T any](items []T, size int) ([][]T, error) {
+	if size <= 0 {
+		return nil, ErrNonPositiveSize
+	}
+	return slide(items, size, make([][]T, 0, max(len(items)-size+1, 0))), nil
+}
+
+func slide[T any](remaining []T, size int, acc [][]T) [][]T {
+	if len(remaining) < size {
+		return acc
+	}
+	return slide(remaining[1:], size, append(acc, remaining[:size:size]))
+}
+
+// TakeWhile возвращает новый срез из начальных элементов, пока pred истинен
+func TakeWhile[T any](items []T, pred func(T) bool) []T {
+	return takeWhile(items, pred, nil)
+}
+
+func takeWhile[T any](remaining []T, pred func(T) bool, acc []T) []T {
+	if len(remaining) == 0 || !pred(remaining[0]) {
+		return acc
+	}
+	return takeWhile(remaining[1:], pred, append(acc, remaining[0]))
+}
+
+// DropWhile возвращает новый срез без начальных элементов, для которых pred истинен
+func DropWhile[T any](items []T, pred func(T) bool) []T {
+	if len(items) == 0 || !pred(items[0]) {
+		return append([]T(nil), items...)
+	}
+	return DropWhile(items[1:], pred)
+}
+
+// Intersperse вставляет sep между соседними элементами, но не по краям
+func Intersperse[T any](items []T, sep T) []T {
+	if len(items) == 0 {
+		return []T{}
+	}
+	return intersperse(items[1:], sep, append(make([]T, 0, 2*len(items)-1), items[0]))
+}
+
+func intersperse[T any](remaining []T, sep T, acc []T) []T {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return intersperse(remaining[1:], sep, append(acc, sep, remaining[0]))
+}
+
+// Span делит items на первом элементе, для которого pred ложен:
+// prefix совпадает с TakeWhile, rest - с DropWhile
+func Span[T any](items []T, pred func(T) bool) (prefix, rest []T) {
+	return span(items, pred, nil)
+}
+
+func span[T any](remaining []T, pred func(T) bool, acc []T) ([]T, []T) {
+	if len(remaining) == 0 || !pred(remaining[0]) {
+		return acc, append([]T(nil), remaining...)
+	}
+	return span(remaining[1:], pred, append(acc, remaining[0]))
+}
+
+// Iterate строит n элементов: seed, next(seed), next(next(seed)), ...
+func Iterate[T any](seed T, next func(T) T, n int) []T {
+	return iterate(seed, next, n, make([]T, 0, max(n, 0)))
+}
+
+func iterate[T any](current T, next func(T) T, n int, acc []T) []T {
+	if len(acc) >= n {
+		return acc
+	}
+	return iterate(next(current), next, n, append(acc, current))
+}
+
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Unzip раскладывает пары на два среза одной длины с сохранением порядка
+func Unzip[A, B any](pairs []Pair[A, B]) ([]A, []B) {
+	return unzip(pairs, make([]A, 0, len(pairs)), make([]B, 0, len(pairs)))
+}
+
+func unzip[A, B any](remaining []Pair[A, B], as []A, bs []B) ([]A, []B) {
+	if len(remaining) == 0 {
+		return as, bs
+	}
+	return unzip(remaining[1:], append(as, remaining[0].First), append(bs, remaining[0].Second))
+}
+
+// GroupConsecutive собирает подряд идущие равные элементы в группы,
+// одинаковые элементы в разных местах среза попадают в разные группы
+func GroupConsecutive[T comparable](items []T) [][]T {
+	return groupConsecutive(items, nil)
+}
+
+func groupConsecutive[T comparable](remaining []T, acc [][]T) [][]T {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	n := runLength(remaining, remaining[0], 0)
+	return groupConsecutive(remaining[n:], append(acc, remaining[:n:n]))
+}
+
+func runLength[T comparable](remaining []T, value T, acc int) int {
+	if len(remaining) == 0 || remaining[0] != value {
+		return acc
+	}
+	return runLength(remaining[1:], value, acc+1)
+}
+
+// MapIndexed применяет f к каждому элементу вместе с его позицией
+func MapIndexed[T, U any](items []T, f func(int, T) U) []U {
+	return mapIndexed(items, f, make([]U, 0, len(items)))
+}
+
+func mapIndexed[T, U any](remaining []T, f func(int, T) U, acc []U) []U {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return mapIndexed(remaining[1:], f, append(acc, f(len(acc), remaining[0])))
+}
+
+// PartitionBy раскладывает элементы по ключу, внутри корзины порядок исходный
+func PartitionBy[T any, K comparable](items []T, key func(T) K) map[K][]T {
+	return partition(items, key, make(map[K][]T))
+}
+
+func partition[T any, K comparable](remaining []T, key func(T) K, acc map[K][]T) map[K][]T {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	k := key(remaining[0])
+	acc[k] = append(acc[k], remaining[0])
+	return partition(remaining[1:], key, acc)
+}
+
+// ParallelFold делит items на части по chunk, сворачивает их через step в workers горутинах
+// и сливает частичные результаты в первый аргумент merge. workers <= 0 - одна горутина.
+// chunk <= 0 паникует как в Chunk: части считаются до запуска горутин, поэтому паника
+// происходит в вызывающей горутине и её можно перехватить через recover
+func ParallelFold[T any](items []T, chunk, workers int, step func(map[string]int, T) map[string]int, merge func(a, b map[string]int)) map[string]int {
+	batches := Chunk(items, chunk)
+	chunks := make(chan []T)
+	parts := make(chan map[string]int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				parts <- fold(c, make(map[string]int), step)
+			}
+		}()
+	}
+	go func() {
+		for _, c := range batches {
+			chunks <- c
+		}
+		close(chunks)
+		wg.Wait()
+		close(parts)
+	}()
+
+	result := make(map[string]int)
+	for part := range parts {
+		merge(result, part)
+	}
+	return result
+}
+
+func fold[T, A any](remaining []T, acc A, step func(A, T) A) A {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return fold(remaining[1:], step(acc, remaining[0]), step)
+}
+
+// FoldMap сворачивает записи m в порядке ключей, заданном less, поэтому результат
+// не зависит от случайного порядка обхода map
+func FoldMap[K comparable, V, A any](m map[K]V, init A, step func(A, K, V) A, less func(K, K) bool) A {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+
+	return fold(keys, init, func(acc A, k K) A { return step(acc, k, m[k]) })
+}
+
+// FoldCounting сворачивает items и дополнительно возвращает число обработанных элементов
+func FoldCounting[T, A any](items []T, init A, step func(A, T) A) (A, int) {
+	return foldCounting(items, init, step, 0)
+}
+
+func foldCounting[T, A any](remaining []T, acc A, step func(A, T) A, n int) (A, int) {
+	if len(remaining) == 0 {
+		return acc, n
+	}
+	return foldCounting(remaining[1:], step(acc, remaining[0]), step, n+1)
+}
@@ -0,0 +1,481 @@
+package corecursive
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFoldUntil(t *testing.T) {
+	stopAt := func(limit int) func([]int, int) ([]int, bool) {
+		return func(acc []int, n int) ([]int, bool) {
+			return append(acc, n), n == limit
+		}
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []int
+	}{
+		{name: "early stop", limit: 2, want: []int{1, 2}},
+		{name: "never stop", limit: 0, want: []int{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FoldUntil([]int{1, 2, 3, 4}, []int(nil), stopAt(tt.limit))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FoldUntil = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZip(t *testing.T) {
+	sum := func(a, b int) int { return a + b }
+
+	tests := []struct {
+		name   string
+		as, bs []int
+		want   []int
+	}{
+		{name: "equal lengths", as: []int{1, 2, 3}, bs: []int{10, 20, 30}, want: []int{11, 22, 33}},
+		{name: "unequal lengths", as: []int{1, 2, 3}, bs: []int{10}, want: []int{11}},
+		{name: "empty", as: nil, bs: []int{10, 20}, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Zip(tt.as, tt.bs, sum); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Zip(%v, %v) = %v, want %v", tt.as, tt.bs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []int
+		want  []int
+	}{
+		{name: "keeps first occurrences", items: []int{3, 1, 3, 2, 1}, want: []int{3, 1, 2}},
+		{name: "all duplicates", items: []int{7, 7, 7}, want: []int{7}},
+		{name: "no duplicates", items: []int{1, 2, 3}, want: []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Dedupe(tt.items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dedupe(%v) = %v, want %v", tt.items, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("strings", func(t *testing.T) {
+		got := Dedupe([]string{"item1", "item2", "item1"})
+		if want := []string{"item1", "item2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Dedupe = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []int
+		size  int
+		want  [][]int
+	}{
+		{name: "even split", items: []int{1, 2, 3, 4}, size: 2, want: [][]int{{1, 2}, {3, 4}}},
+		{name: "remainder", items: []int{1, 2, 3, 4, 5}, size: 2, want: [][]int{{1, 2}, {3, 4}, {5}}},
+		{name: "oversized chunk", items: []int{1, 2, 3}, size: 10, want: [][]int{{1, 2, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Chunk(tt.items, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Chunk(%v, %d) = %v, want %v", tt.items, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverse(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []int
+		want  []int
+	}{
+		{name: "even", items: []int{1, 2, 3, 4}, want: []int{4, 3, 2, 1}},
+		{name: "odd", items: []int{1, 2, 3}, want: []int{3, 2, 1}},
+		{name: "empty", items: nil, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]int(nil), tt.items...)
+			if got := Reverse(tt.items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reverse(%v) = %v, want %v", tt.items, got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.items, original) {
+				t.Errorf("Reverse mutated its input: %v, was %v", tt.items, original)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	even := func(n int) bool { return n%2 == 0 }
+
+	tests := []struct {
+		name  string
+		items []int
+		want  int
+	}{
+		{name: "even numbers", items: []int{1, 2, 3, 4, 6}, want: 3},
+		{name: "zero matches", items: []int{1, 3, 5}, want: 0},
+		{name: "empty", items: nil, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Count(tt.items, even); got != tt.want {
+				t.Errorf("Count(%v) = %d, want %d", tt.items, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxByMinBy(t *testing.T) {
+	type player struct {
+		id    string
+		score int
+	}
+	score := func(p player) int { return p.score }
+	players := []player{{"a", 10}, {"b", 30}, {"c", 5}, {"d", 30}, {"e", 5}}
+
+	if got, ok := MaxBy(players, score); !ok || got.id != "b" {
+		t.Errorf("MaxBy = %v, %v, want b, true", got, ok)
+	}
+	if got, ok := MinBy(players, score); !ok || got.id != "c" {
+		t.Errorf("MinBy = %v, %v, want c, true", got, ok)
+	}
+	if _, ok := MaxBy([]player(nil), score); ok {
+		t.Error("MaxBy on empty slice reported ok")
+	}
+	if _, ok := MinBy([]player(nil), score); ok {
+		t.Error("MinBy on empty slice reported ok")
+	}
+}
+
+func TestFlatMap(t *testing.T) {
+	divisors := func(n int) []int {
+		var ds []int
+		for d := 1; d <= n; d++ {
+			if n%d == 0 {
+				ds = append(ds, d)
+			}
+		}
+		return ds
+	}
+	if got, want := FlatMap([]int{4, 1, 6}, divisors), []int{1, 2, 4, 1, 1, 2, 3, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FlatMap divisors = %v, want %v", got, want)
+	}
+
+	type event struct {
+		id   string
+		tags []string
+	}
+	events := []event{{"e1", []string{"pvp", "ranked"}}, {"e2", nil}, {"e3", []string{"coop"}}}
+	tags := FlatMap(events, func(e event) []string { return e.tags })
+	if want := []string{"pvp", "ranked", "coop"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("FlatMap tags = %v, want %v", tags, want)
+	}
+}
+
+func TestAnyAllShortCircuit(t *testing.T) {
+	var calls int
+	positive := func(n int) bool {
+		calls++
+		return n > 0
+	}
+
+	calls = 0
+	if !Any([]int{-1, 2, 3, 4}, positive) || calls != 2 {
+		t.Errorf("Any stopped after %d calls, want true after 2", calls)
+	}
+	calls = 0
+	if All([]int{1, -2, 3, 4}, positive) || calls != 2 {
+		t.Errorf("All stopped after %d calls, want false after 2", calls)
+	}
+}
+
+func TestAnyAllEmpty(t *testing.T) {
+	never := func(int) bool { return false }
+	if Any(nil, never) {
+		t.Error("Any on empty slice = true, want false")
+	}
+	if !All(nil, never) {
+		t.Error("All on empty slice = false, want true")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	items := []int{1, 2, 3}
+
+	tests := []struct {
+		name string
+		size int
+		want [][]int
+	}{
+		{name: "size 1", size: 1, want: [][]int{{1}, {2}, {3}}},
+		{name: "size equal to length", size: 3, want: [][]int{{1, 2, 3}}},
+		{name: "size larger than length", size: 4, want: [][]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SlidingWindow(items, tt.size)
+			if err != nil {
+				t.Fatalf("SlidingWindow error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SlidingWindow(%v, %d) = %v, want %v", items, tt.size, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := SlidingWindow(items, 0); !errors.Is(err, ErrNonPositiveSize) {
+		t.Errorf("SlidingWindow size 0 error = %v, want %v", err, ErrNonPositiveSize)
+	}
+}
+
+func TestTakeWhileDropWhile(t *testing.T) {
+	items := []int{1, 2, 3}
+
+	tests := []struct {
+		name     string
+		pred     func(int) bool
+		wantTake []int
+		wantDrop []int
+	}{
+		{name: "matching none", pred: func(n int) bool { return n > 5 }, wantTake: nil, wantDrop: []int{1, 2, 3}},
+		{name: "matching some", pred: func(n int) bool { return n < 3 }, wantTake: []int{1, 2}, wantDrop: []int{3}},
+		{name: "matching all", pred: func(n int) bool { return n > 0 }, wantTake: []int{1, 2, 3}, wantDrop: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TakeWhile(items, tt.pred); !reflect.DeepEqual(got, tt.wantTake) {
+				t.Errorf("TakeWhile = %v, want %v", got, tt.wantTake)
+			}
+			if got := DropWhile(items, tt.pred); !reflect.DeepEqual(got, tt.wantDrop) {
+				t.Errorf("DropWhile = %v, want %v", got, tt.wantDrop)
+			}
+		})
+	}
+}
+
+func TestIntersperse(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []string
+		want  []string
+	}{
+		{name: "zero elements", items: nil, want: []string{}},
+		{name: "one element", items: []string{"a"}, want: []string{"a"}},
+		{name: "several elements", items: []string{"a", "b", "c"}, want: []string{"a", "|", "b", "|", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Intersperse(tt.items, "|"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Intersperse(%v) = %v, want %v", tt.items, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpan(t *testing.T) {
+	items := []int{1, 2, 3, 4}
+
+	tests := []struct {
+		name       string
+		pred       func(int) bool
+		wantPrefix []int
+		wantRest   []int
+	}{
+		{name: "middle split", pred: func(n int) bool { return n < 3 }, wantPrefix: []int{1, 2}, wantRest: []int{3, 4}},
+		{name: "all matching", pred: func(n int) bool { return n > 0 }, wantPrefix: []int{1, 2, 3, 4}, wantRest: nil},
+		{name: "none matching", pred: func(n int) bool { return n > 5 }, wantPrefix: nil, wantRest: []int{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, rest := Span(items, tt.pred)
+			if !reflect.DeepEqual(prefix, tt.wantPrefix) || !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("Span = %v, %v, want %v, %v", prefix, rest, tt.wantPrefix, tt.wantRest)
+			}
+			if !reflect.DeepEqual(prefix, TakeWhile(items, tt.pred)) || !reflect.DeepEqual(rest, DropWhile(items, tt.pred)) {
+				t.Errorf("Span disagrees with TakeWhile/DropWhile")
+			}
+		})
+	}
+}
+
+func TestIterate(t *testing.T) {
+	next := func(n int) int { return n + 10 }
+
+	tests := []struct {
+		name string
+		n    int
+		want []int
+	}{
+		{name: "n=0", n: 0, want: []int{}},
+		{name: "n=1", n: 1, want: []int{10}},
+		{name: "small ramp", n: 4, want: []int{10, 20, 30, 40}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Iterate(10, next, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Iterate(10, +10, %d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcat(t *testing.T) {
+	tests := []struct {
+		name   string
+		slices [][]int
+		want   []int
+	}{
+		{name: "no args", slices: nil, want: []int{}},
+		{name: "empty slices", slices: [][]int{nil, {}, nil}, want: []int{}},
+		{name: "keeps order", slices: [][]int{{1, 2}, nil, {3}, {4, 5}}, want: []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Concat(tt.slices...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Concat(%v) = %v, want %v", tt.slices, got, tt.want)
+			}
+		})
+	}
+
+	if got := Concat[int](); got == nil || len(got) != 0 {
+		t.Errorf("Concat() = %#v, want empty non-nil slice", got)
+	}
+}
+
+func TestUnzip(t *testing.T) {
+	pairs := []Pair[string, int]{{"a", 1}, {"b", 2}, {"c", 3}}
+	firsts, seconds := Unzip(pairs)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(firsts, want) {
+		t.Errorf("Unzip firsts = %v, want %v", firsts, want)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(seconds, want) {
+		t.Errorf("Unzip seconds = %v, want %v", seconds, want)
+	}
+
+	firsts, seconds = Unzip[string, int](nil)
+	if len(firsts) != 0 || len(seconds) != 0 {
+		t.Errorf("Unzip(nil) = %v, %v, want empty slices", firsts, seconds)
+	}
+}
+
+func TestGroupConsecutive(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []string
+		want  [][]string
+	}{
+		{name: "alternating", items: []string{"a", "b", "a", "b"}, want: [][]string{{"a"}, {"b"}, {"a"}, {"b"}}},
+		{name: "all equal", items: []string{"a", "a", "a"}, want: [][]string{{"a", "a", "a"}}},
+		{name: "single element", items: []string{"a"}, want: [][]string{{"a"}}},
+		{name: "runs", items: []string{"a", "a", "b", "a"}, want: [][]string{{"a", "a"}, {"b"}, {"a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GroupConsecutive(tt.items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupConsecutive(%v) = %v, want %v", tt.items, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapIndexedMatchesLoop(t *testing.T) {
+	items := []string{"a", "b", "c", "d"}
+	label := func(i int, s string) string { return fmt.Sprintf("%d:%s", i, s) }
+
+	want := make([]string, 0, len(items))
+	for i, s := range items {
+		want = append(want, label(i, s))
+	}
+	if got := MapIndexed(items, label); !reflect.DeepEqual(got, want) {
+		t.Errorf("MapIndexed = %v, want %v", got, want)
+	}
+}
+
+func TestPartitionBy(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7}
+	parity := func(n int) string {
+		if n%2 == 0 {
+			return "even"
+		}
+		return "odd"
+	}
+	want := map[string][]int{"odd": {1, 3, 5, 7}, "even": {2, 4, 6}}
+
+	if got := PartitionBy(items, parity); !reflect.DeepEqual(got, want) {
+		t.Errorf("PartitionBy = %v, want %v", got, want)
+	}
+}
+
+// Запускать и с -race: части сворачиваются в нескольких горутинах
+func TestParallelFoldMatchesSequential(t *testing.T) {
+	words := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		words = append(words, fmt.Sprintf("w%d", i%13))
+	}
+	countWord := func(acc map[string]int, w string) map[string]int {
+		acc[w]++
+		return acc
+	}
+	merge := func(dst, src map[string]int) {
+		for k, v := range src {
+			dst[k] += v
+		}
+	}
+	want := fold(words, make(map[string]int), countWord)
+
+	for _, workers := range []int{0, 1, 4, 16} {
+		if got := ParallelFold(words, 37, workers, countWord, merge); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParallelFold(workers=%d) = %v, want %v", workers, got, want)
+		}
+	}
+}
+
+func TestParallelFoldNonPositiveChunkPanicsInCaller(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("ParallelFold with chunk 0 did not panic")
+		}
+	}()
+	ParallelFold([]int{1}, 0, 2, func(acc map[string]int, _ int) map[string]int { return acc }, func(_, _ map[string]int) {})
+}
+
+func TestFoldMapDeterministic(t *testing.T) {
+	m := map[string]int{"d": 4, "a": 1, "c": 3, "b": 2, "e": 5}
+	join := func(acc string, k string, v int) string { return acc + fmt.Sprintf("%s%d", k, v) }
+	less := func(a, b string) bool { return a < b }
+
+	for i := 0; i < 50; i++ {
+		if got := FoldMap(m, "", join, less); got != "a1b2c3d4e5" {
+			t.Fatalf("FoldMap = %q, want %q", got, "a1b2c3d4e5")
+		}
+	}
+}
+
+func TestFoldCounting(t *testing.T) {
+	sum := func(acc, n int) int { return acc + n }
+
+	for _, items := range [][]int{{1, 2, 3, 4}, nil} {
+		got, n := FoldCounting(items, 0, sum)
+		if want := fold(items, 0, sum); got != want || n != len(items) {
+			t.Errorf("FoldCounting(%v) = %d, %d, want %d, %d", items, got, n, want, len(items))
+		}
+	}
+}
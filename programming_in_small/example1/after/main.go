@@ -1,29 +1,438 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+
+	"hard-work/programming_in_small/corecursive"
 )
 
 type LogEntry struct {
+	Level     string
+	Component string
+}
+
+type LevelCount struct {
 	Level string
+	Count int
 }
 
+var (
+	ErrLimitExceeded = errors.New("log entries limit exceeded")
+	ErrInvalidWindow = errors.New("window must be positive")
+)
+
+// Уровни по возрастанию серьёзности
+var knownLevels = []string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
 func aggregateLogsCorecursive(logs []LogEntry) map[string]int {
-	return aggregate(logs, make(map[string]int))
+	return AggregateBy(logs, byLevel)
+}
+
+// Подсчёт уровней, заданных строками, " info" и "INFO" считаются одним уровнем
+func AggregateLevels(levels []string) map[string]int {
+	return countLevels(levels, make(map[string]int))
+}
+
+func countLevels(remaining []string, acc map[string]int) map[string]int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	acc[normalizeLevel(remaining[0])]++
+	return countLevels(remaining[1:], acc)
+}
+
+func normalizeLevel(level string) string {
+	return strings.ToUpper(strings.TrimSpace(level))
+}
+
+// Подсчёт записей по произвольному ключу, например по компоненту
+func AggregateBy(logs []LogEntry, key func(LogEntry) string) map[string]int {
+	return aggregate(logs, key, make(map[string]int))
+}
+
+func aggregate(remaining []LogEntry, key func(LogEntry) string, acc map[string]int) map[string]int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	acc[key(remaining[0])]++
+	return aggregate(remaining[1:], key, acc)
+}
+
+func byLevel(log LogEntry) string {
+	return normalizeLevel(log.Level)
+}
+
+// Агрегация до первого ERROR включительно
+func aggregateLogsUntilError(logs []LogEntry) map[string]int {
+	return corecursive.FoldUntil(logs, make(map[string]int), func(acc map[string]int, log LogEntry) (map[string]int, bool) {
+		return countLevel(acc, log), isError(log)
+	})
+}
+
+// Счётчики в порядке серьёзности, неизвестные уровни - в конце по алфавиту
+func AggregateLogsOrdered(logs []LogEntry) []LevelCount {
+	stats := aggregateLogsCorecursive(logs)
+	ordered := collectLevels(knownLevels, stats, nil)
+
+	var unknown []string
+	for level := range stats {
+		if !isKnownLevel(level) {
+			unknown = append(unknown, level)
+		}
+	}
+	sort.Strings(unknown)
+
+	return collectLevels(unknown, stats, ordered)
+}
+
+func collectLevels(remaining []string, stats map[string]int, acc []LevelCount) []LevelCount {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	if count, ok := stats[remaining[0]]; ok {
+		acc = append(acc, LevelCount{Level: remaining[0], Count: count})
+	}
+	return collectLevels(remaining[1:], stats, acc)
+}
+
+// Все известные уровни присутствуют в результате, даже с нулевым счётчиком
+func AggregateLogsWithZeros(logs []LogEntry) map[string]int {
+	return aggregate(logs, byLevel, seedLevels(knownLevels, make(map[string]int)))
+}
+
+func seedLevels(remaining []string, acc map[string]int) map[string]int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	acc[remaining[0]] = 0
+	return seedLevels(remaining[1:], acc)
+}
+
+// Агрегация с ограничением на число записей, max = 0 - без ограничений.
+// Лимит проверяется до обработки, чтобы не тратить работу на заведомо отклонённый вход
+func AggregateLogsBounded(logs []LogEntry, max int) (map[string]int, error) {
+	if max > 0 && len(logs) > max {
+		return nil, fmt.Errorf("%w: %d > %d", ErrLimitExceeded, len(logs), max)
+	}
+	return aggregateLogsCorecursive(logs), nil
+}
+
+// Потоковая агрегация: копия текущих счётчиков каждые every записей и итоговая - при закрытии in.
+// every <= 0 - только итоговый снимок. При отмене ctx канал закрывается без итогового снимка
+func AggregateLogStreamFlushing(ctx context.Context, in <-chan LogEntry, every int) <-chan map[string]int {
+	out := make(chan map[string]int)
+	go func() {
+		defer close(out)
+		consume(ctx, in, every, out)
+	}()
+	return out
+}
+
+func consume(ctx context.Context, in <-chan LogEntry, every int, out chan<- map[string]int) {
+	acc := make(map[string]int)
+	seen := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case log, ok := <-in:
+			if !ok {
+				emit(ctx, copyCounts(acc), out)
+				return
+			}
+
+			countLevel(acc, log)
+			seen++
+			if every > 0 && seen%every == 0 && !emit(ctx, copyCounts(acc), out) {
+				return
+			}
+		}
+	}
+}
+
+func emit(ctx context.Context, snapshot map[string]int, out chan<- map[string]int) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case out <- snapshot:
+		return true
+	}
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	snapshot := make(map[string]int, len(counts))
+	for level, count := range counts {
+		snapshot[level] = count
+	}
+	return snapshot
+}
+
+// Агрегация пачками по size записей, по одному результату на пачку
+func aggregateLogsChunked(logs []LogEntry, size int) []map[string]int {
+	return aggregateChunks(corecursive.Chunk(logs, size), nil)
+}
+
+func aggregateChunks(remaining [][]LogEntry, acc []map[string]int) []map[string]int {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return aggregateChunks(remaining[1:], append(acc, aggregateLogsCorecursive(remaining[0])))
+}
+
+// ERROR-записи, отобранные по пачкам из size записей и склеенные обратно в исходном порядке
+func errorLogsChunked(logs []LogEntry, size int) []LogEntry {
+	return corecursive.Concat(errorsPerChunk(corecursive.Chunk(logs, size), nil)...)
+}
+
+func errorsPerChunk(remaining [][]LogEntry, acc [][]LogEntry) [][]LogEntry {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return errorsPerChunk(remaining[1:], append(acc, corecursive.PartitionBy(remaining[0], isError)[true]))
+}
+
+func countErrors(logs []LogEntry) int {
+	return corecursive.Count(logs, isError)
+}
+
+// Доля ERROR в каждой пачке, для пустой пачки - 0, а не NaN
+func ErrorRateByBatch(batches ...[]LogEntry) []float64 {
+	return errorRates(batches, make([]float64, 0, len(batches)))
+}
+
+func errorRates(remaining [][]LogEntry, acc []float64) []float64 {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	rate := 0.0
+	if len(remaining[0]) > 0 {
+		rate = float64(countErrors(remaining[0])) / float64(len(remaining[0]))
+	}
+	return errorRates(remaining[1:], append(acc, rate))
+}
+
+// Записи до первого ERROR, не включая его
+func logsBeforeError(logs []LogEntry) []LogEntry {
+	return corecursive.TakeWhile(logs, func(log LogEntry) bool { return !isError(log) })
+}
+
+// Сжатие последовательности уровней: соседние одинаковые уровни - одна запись со счётчиком
+func runLengthLevels(logs []LogEntry) []LevelCount {
+	return encodeRuns(corecursive.GroupConsecutive(extractLevels(logs, make([]string, 0, len(logs)))), nil)
+}
+
+func extractLevels(remaining []LogEntry, acc []string) []string {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return extractLevels(remaining[1:], append(acc, byLevel(remaining[0])))
+}
+
+func encodeRuns(remaining [][]string, acc []LevelCount) []LevelCount {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return encodeRuns(remaining[1:], append(acc, LevelCount{Level: remaining[0][0], Count: len(remaining[0])}))
+}
+
+// Скользящее среднее долей ERROR по window пачкам, по одному значению на пачку.
+// Первые window-1 значений усредняются по тем пачкам, что уже есть, а не пропускаются
+func MovingAverageErrorRate(batches [][]LogEntry, window int) ([]float64, error) {
+	if window <= 0 {
+		return nil, ErrInvalidWindow
+	}
+
+	rates := ErrorRateByBatch(batches...)
+	return movingAverage(rates, rates, window, 0, make([]float64, 0, len(rates))), nil
+}
+
+func movingAverage(rates, remaining []float64, window int, sum float64, acc []float64) []float64 {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	i := len(acc)
+	sum += remaining[0]
+	if i >= window {
+		sum -= rates[i-window]
+	}
+	return movingAverage(rates, remaining[1:], window, sum, append(acc, sum/float64(min(i+1, window))))
+}
+
+// Число ERROR в каждом окне из window последних записей, начиная с первого полного окна.
+// Счётчик обновляется на сдвиге: плюс вошедшая запись, минус выбывшая.
+// Для window <= 0 или длиннее logs окон нет
+func SlidingErrorCounts(logs []LogEntry, window int) []int {
+	if window <= 0 || window > len(logs) {
+		return nil
+	}
+
+	first := countErrors(logs[:window])
+	return slideErrors(logs, window, window, first, append(make([]int, 0, len(logs)-window+1), first))
+}
+
+func slideErrors(logs []LogEntry, window, next, current int, acc []int) []int {
+	if next == len(logs) {
+		return acc
+	}
+
+	if isError(logs[next]) {
+		current++
+	}
+	if isError(logs[next-window]) {
+		current--
+	}
+	return slideErrors(logs, window, next+1, current, append(acc, current))
+}
+
+func isError(log LogEntry) bool {
+	return byLevel(log) == "ERROR"
+}
+
+// Агрегация вместе с метрикой числа обработанных записей
+func aggregateLogsCounted(logs []LogEntry) (map[string]int, int) {
+	return corecursive.FoldCounting(logs, make(map[string]int), countLevel)
+}
+
+func countLevel(acc map[string]int, log LogEntry) map[string]int {
+	acc[byLevel(log)]++
+	return acc
+}
+
+// Та же агрегация, но пачки по size записей считаются параллельно
+func aggregateLogsParallel(logs []LogEntry, size, workers int) map[string]int {
+	return corecursive.ParallelFold(logs, size, workers, countLevel, mergeCounts)
+}
+
+func mergeCounts(dst, src map[string]int) {
+	for level, count := range src {
+		dst[level] += count
+	}
+}
+
+// Сумма счётчиков шардов и уровни, встретившиеся больше чем в одном шарде, по алфавиту
+func MergeLogCounts(maps ...map[string]int) (merged map[string]int, overlappingKeys []string) {
+	merged = make(map[string]int)
+	shards := make(map[string]int)
+	for _, counts := range maps {
+		mergeCounts(merged, counts)
+		for level := range counts {
+			shards[level]++
+		}
+	}
+
+	for level, n := range shards {
+		if n > 1 {
+			overlappingKeys = append(overlappingKeys, level)
+		}
+	}
+	sort.Strings(overlappingKeys)
+	return merged, overlappingKeys
 }
 
-func aggregate(remaining []LogEntry, acc map[string]int) map[string]int {
+// Самый частый уровень; при равенстве побеждает более серьёзный,
+// неизвестные уровни слабее известных и сравниваются по алфавиту
+func DominantLevel(logs []LogEntry) (string, bool) {
+	counts := AggregateLogsOrdered(logs)
+	if len(counts) == 0 {
+		return "", false
+	}
+	return dominant(counts[1:], counts[0]).Level, true
+}
+
+// k самых частых уровней по убыванию счётчика, при равенстве - более серьёзный первым.
+// k <= 0 - пустой результат, k больше числа уровней - все уровни
+func TopLevels(logs []LogEntry, k int) []LevelCount {
+	if k <= 0 {
+		return []LevelCount{}
+	}
+
+	counts := append([]LevelCount{}, AggregateLogsOrdered(logs)...)
+	sort.SliceStable(counts, func(i, j int) bool { return outranks(counts[i], counts[j]) })
+	return counts[:min(k, len(counts))]
+}
+
+func dominant(remaining []LevelCount, acc LevelCount) LevelCount {
 	if len(remaining) == 0 {
 		return acc
 	}
 
-	acc[remaining[0].Level]++
-	return aggregate(remaining[1:], acc)
+	if outranks(remaining[0], acc) {
+		acc = remaining[0]
+	}
+	return dominant(remaining[1:], acc)
+}
+
+func outranks(a, b LevelCount) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	if severity(a.Level) != severity(b.Level) {
+		return severity(a.Level) > severity(b.Level)
+	}
+	return a.Level < b.Level
+}
+
+// Позиция в knownLevels начиная с 1, для неизвестного уровня - 0
+func severity(level string) int {
+	for i, known := range knownLevels {
+		if known == level {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func isKnownLevel(level string) bool {
+	return severity(level) > 0
 }
 
 func main() {
 	logs := []LogEntry{
-		{"INFO"}, {"ERROR"}, {"INFO"}, {"DEBUG"}, {"ERROR"},
+		{"INFO", "api"}, {"ERROR", "db"}, {"INFO", "api"}, {"DEBUG", "cache"}, {"ERROR", "api"},
 	}
 	fmt.Println(aggregateLogsCorecursive(logs))
-}
\ No newline at end of file
+	fmt.Println(AggregateLevels([]string{"info", "INFO", " Error"}))
+	fmt.Println(AggregateBy(logs, func(log LogEntry) string { return log.Component }))
+	fmt.Println(aggregateLogsUntilError(logs))
+	fmt.Println(AggregateLogsOrdered(logs))
+	fmt.Println(DominantLevel(logs))
+	fmt.Println(TopLevels(logs, 2))
+	fmt.Println(AggregateLogsWithZeros(logs))
+	fmt.Println(AggregateLogsBounded(logs, 3))
+	fmt.Println(aggregateLogsChunked(logs, 2))
+	fmt.Println(errorLogsChunked(logs, 2))
+	fmt.Println(aggregateLogsParallel(logs, 2, 2))
+	fmt.Println(MergeLogCounts(aggregateLogsChunked(logs, 2)...))
+	stats, processed := aggregateLogsCounted(logs)
+	fmt.Println(stats, "processed:", processed)
+	fmt.Println(countErrors(logs))
+	fmt.Println(SlidingErrorCounts(logs, 2))
+	fmt.Println(corecursive.Any(logs, isError))
+	fmt.Println(logsBeforeError(logs))
+	fmt.Println(corecursive.PartitionBy(logs, byLevel)["ERROR"])
+	fmt.Println(runLengthLevels(append(logs, LogEntry{Level: "ERROR"})))
+	fmt.Println(corecursive.Span(logs, func(log LogEntry) bool { return log.Component == "api" }))
+	fmt.Println(ErrorRateByBatch(logs[:2], nil, logs[2:]))
+	fmt.Println(MovingAverageErrorRate([][]LogEntry{logs[:2], nil, logs[2:]}, 2))
+
+	stream := make(chan LogEntry)
+	go func() {
+		defer close(stream)
+		for _, log := range logs {
+			stream <- log
+		}
+	}()
+	for snapshot := range AggregateLogStreamFlushing(context.Background(), stream, 2) {
+		fmt.Println(snapshot)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAggregateLogsOrdered(t *testing.T) {
+	logs := []LogEntry{
+		{Level: "TRACE"}, {Level: "ERROR"}, {Level: "INFO"}, {Level: "AUDIT"},
+		{Level: "ERROR"}, {Level: "DEBUG"}, {Level: "TRACE"},
+	}
+	want := []LevelCount{
+		{"DEBUG", 1}, {"INFO", 1}, {"ERROR", 2}, {"AUDIT", 1}, {"TRACE", 2},
+	}
+
+	if got := AggregateLogsOrdered(logs); !reflect.DeepEqual(got, want) {
+		t.Errorf("AggregateLogsOrdered = %v, want %v", got, want)
+	}
+}
+
+func TestAggregateLogsWithZerosWithoutDebug(t *testing.T) {
+	logs := []LogEntry{{Level: "INFO"}, {Level: "ERROR"}, {Level: "INFO"}}
+	want := map[string]int{"DEBUG": 0, "INFO": 2, "WARN": 0, "ERROR": 1, "FATAL": 0}
+
+	if got := AggregateLogsWithZeros(logs); !reflect.DeepEqual(got, want) {
+		t.Errorf("AggregateLogsWithZeros = %v, want %v", got, want)
+	}
+}
+
+func TestAggregateLogsBounded(t *testing.T) {
+	logs := []LogEntry{{Level: "INFO"}, {Level: "ERROR"}, {Level: "INFO"}}
+
+	tests := []struct {
+		name    string
+		max     int
+		wantErr error
+	}{
+		{name: "under the limit", max: 4},
+		{name: "at the limit", max: 3},
+		{name: "over the limit", max: 2, wantErr: ErrLimitExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AggregateLogsBounded(logs, tt.max)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AggregateLogsBounded error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if got != nil {
+					t.Errorf("AggregateLogsBounded = %v, want nil on error", got)
+				}
+				return
+			}
+			if want := aggregateLogsCorecursive(logs); !reflect.DeepEqual(got, want) {
+				t.Errorf("AggregateLogsBounded = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func streamLogs(logs []LogEntry) <-chan LogEntry {
+	ch := make(chan LogEntry)
+	go func() {
+		defer close(ch)
+		for _, log := range logs {
+			ch <- log
+		}
+	}()
+	return ch
+}
+
+func TestAggregateLogStreamFlushing(t *testing.T) {
+	logs := []LogEntry{{Level: "INFO"}, {Level: "ERROR"}, {Level: "INFO"}, {Level: "DEBUG"}, {Level: "ERROR"}}
+
+	tests := []struct {
+		name  string
+		every int
+		want  []map[string]int
+	}{
+		{
+			name:  "snapshot every two entries and a final one",
+			every: 2,
+			want: []map[string]int{
+				{"INFO": 1, "ERROR": 1},
+				{"INFO": 2, "ERROR": 1, "DEBUG": 1},
+				{"INFO": 2, "ERROR": 2, "DEBUG": 1},
+			},
+		},
+		{
+			name:  "final snapshot only",
+			every: 0,
+			want:  []map[string]int{{"INFO": 2, "ERROR": 2, "DEBUG": 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []map[string]int
+			for snapshot := range AggregateLogStreamFlushing(context.Background(), streamLogs(logs), tt.every) {
+				got = append(got, snapshot)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("snapshots = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateLogStreamFlushingSnapshotsAreCopies(t *testing.T) {
+	logs := []LogEntry{{Level: "INFO"}, {Level: "ERROR"}, {Level: "INFO"}}
+
+	var got []map[string]int
+	for snapshot := range AggregateLogStreamFlushing(context.Background(), streamLogs(logs), 1) {
+		snapshot["INFO"] += 100
+		got = append(got, snapshot)
+	}
+	want := []map[string]int{
+		{"INFO": 101},
+		{"INFO": 101, "ERROR": 1},
+		{"INFO": 102, "ERROR": 1},
+		{"INFO": 102, "ERROR": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshots = %v, want %v", got, want)
+	}
+}
+
+func TestCountErrors(t *testing.T) {
+	logs := []LogEntry{{Level: "ERROR"}, {Level: "INFO"}, {Level: " error"}, {Level: "WARN"}}
+	if got := countErrors(logs); got != 2 {
+		t.Errorf("countErrors = %d, want 2", got)
+	}
+	if got := countErrors(logs[1:2]); got != 0 {
+		t.Errorf("countErrors without errors = %d, want 0", got)
+	}
+}
+
+func TestErrorRateByBatch(t *testing.T) {
+	got := ErrorRateByBatch(
+		[]LogEntry{{Level: "ERROR"}, {Level: "INFO"}},
+		nil,
+		[]LogEntry{{Level: "ERROR"}, {Level: "ERROR"}, {Level: "INFO"}, {Level: "ERROR"}},
+		[]LogEntry{{Level: "DEBUG"}},
+	)
+	if want := []float64{0.5, 0, 0.75, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ErrorRateByBatch = %v, want %v", got, want)
+	}
+}
+
+func TestAggregateBy(t *testing.T) {
+	logs := []LogEntry{
+		{"INFO", "api"}, {"ERROR", "db"}, {"INFO", "api"}, {"DEBUG", "cache"}, {"ERROR", "api"},
+	}
+
+	tests := []struct {
+		name string
+		key  func(LogEntry) string
+		want map[string]int
+	}{
+		{name: "by level", key: byLevel, want: map[string]int{"INFO": 2, "ERROR": 2, "DEBUG": 1}},
+		{
+			name: "by component",
+			key:  func(log LogEntry) string { return log.Component },
+			want: map[string]int{"api": 3, "db": 1, "cache": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AggregateBy(logs, tt.key); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AggregateBy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMovingAverageErrorRate(t *testing.T) {
+	half := []LogEntry{{Level: "ERROR"}, {Level: "INFO"}}
+	clean := []LogEntry{{Level: "INFO"}, {Level: "INFO"}}
+	failing := []LogEntry{{Level: "ERROR"}, {Level: "ERROR"}}
+
+	tests := []struct {
+		name    string
+		batches [][]LogEntry
+		want    []float64
+	}{
+		{name: "steady series", batches: [][]LogEntry{half, half, half, half}, want: []float64{0.5, 0.5, 0.5, 0.5}},
+		{name: "spike", batches: [][]LogEntry{clean, clean, failing, clean}, want: []float64{0, 0, 0.5, 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MovingAverageErrorRate(tt.batches, 2)
+			if err != nil {
+				t.Fatalf("MovingAverageErrorRate error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MovingAverageErrorRate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := MovingAverageErrorRate([][]LogEntry{half}, 0); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("MovingAverageErrorRate window 0 error = %v, want %v", err, ErrInvalidWindow)
+	}
+}
+
+func TestLevelNormalizationMixedCase(t *testing.T) {
+	logs := []LogEntry{{Level: "info"}, {Level: " INFO"}, {Level: "Error "}, {Level: "warn"}}
+	want := map[string]int{"INFO": 2, "ERROR": 1}
+	wantAll := map[string]int{"INFO": 2, "ERROR": 1, "WARN": 1}
+
+	if got := AggregateLevels([]string{"info", " INFO", "Error ", "warn"}); !reflect.DeepEqual(got, wantAll) {
+		t.Errorf("AggregateLevels = %v, want %v", got, wantAll)
+	}
+	if got := aggregateLogsCorecursive(logs); !reflect.DeepEqual(got, wantAll) {
+		t.Errorf("aggregateLogsCorecursive = %v, want %v", got, wantAll)
+	}
+	if got := aggregateLogsUntilError(logs); !reflect.DeepEqual(got, want) {
+		t.Errorf("aggregateLogsUntilError = %v, want %v", got, want)
+	}
+
+	var last map[string]int
+	for snapshot := range AggregateLogStreamFlushing(context.Background(), streamLogs(logs), 0) {
+		last = snapshot
+	}
+	if !reflect.DeepEqual(last, wantAll) {
+		t.Errorf("AggregateLogStreamFlushing final snapshot = %v, want %v", last, wantAll)
+	}
+}
+
+func TestSlidingErrorCountsMatchesNaive(t *testing.T) {
+	logs := []LogEntry{
+		{Level: "ERROR"}, {Level: "INFO"}, {Level: "ERROR"}, {Level: "ERROR"},
+		{Level: "DEBUG"}, {Level: "INFO"}, {Level: "ERROR"}, {Level: "WARN"},
+	}
+
+	for window := 0; window <= len(logs)+1; window++ {
+		var want []int
+		for start := 0; window > 0 && start+window <= len(logs); start++ {
+			want = append(want, countErrors(logs[start:start+window]))
+		}
+		got := SlidingErrorCounts(logs, window)
+		if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("SlidingErrorCounts(window=%d) = %v, want %v", window, got, want)
+		}
+	}
+}
+
+func TestMergeLogCounts(t *testing.T) {
+	tests := []struct {
+		name        string
+		shards      []map[string]int
+		want        map[string]int
+		wantOverlap []string
+	}{
+		{
+			name:   "disjoint shards",
+			shards: []map[string]int{{"INFO": 2}, {"ERROR": 1}},
+			want:   map[string]int{"INFO": 2, "ERROR": 1},
+		},
+		{
+			name:        "overlapping shards",
+			shards:      []map[string]int{{"INFO": 2, "ERROR": 1}, {"ERROR": 3, "DEBUG": 1}, {"INFO": 1, "ERROR": 1}},
+			want:        map[string]int{"INFO": 3, "ERROR": 5, "DEBUG": 1},
+			wantOverlap: []string{"ERROR", "INFO"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, overlap := MergeLogCounts(tt.shards...)
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(overlap, tt.wantOverlap) {
+				t.Errorf("MergeLogCounts = %v, %v, want %v, %v", got, overlap, tt.want, tt.wantOverlap)
+			}
+		})
+	}
+}
+
+func TestDominantLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		logs   []LogEntry
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "clear winner",
+			logs:   []LogEntry{{Level: "INFO"}, {Level: "ERROR"}, {Level: "INFO"}},
+			want:   "INFO",
+			wantOK: true,
+		},
+		{
+			name:   "severity breaks a tie",
+			logs:   []LogEntry{{Level: "INFO"}, {Level: "ERROR"}, {Level: "INFO"}, {Level: "ERROR"}, {Level: "TRACE"}, {Level: "TRACE"}},
+			want:   "ERROR",
+			wantOK: true,
+		},
+		{name: "empty", logs: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := DominantLevel(tt.logs); got != tt.want || ok != tt.wantOK {
+				t.Errorf("DominantLevel = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTopLevels(t *testing.T) {
+	logs := []LogEntry{
+		{Level: "DEBUG"}, {Level: "ERROR"}, {Level: "INFO"}, {Level: "WARN"},
+		{Level: "ERROR"}, {Level: "DEBUG"}, {Level: "INFO"}, {Level: "ERROR"},
+	}
+	all := []LevelCount{{"ERROR", 3}, {"INFO", 2}, {"DEBUG", 2}, {"WARN", 1}}
+
+	tests := []struct {
+		name string
+		k    int
+		want []LevelCount
+	}{
+		{name: "k smaller than the level count", k: 2, want: all[:2]},
+		{name: "k equal to the level count", k: 4, want: all},
+		{name: "k larger than the level count", k: 10, want: all},
+		{name: "k zero", k: 0, want: []LevelCount{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TopLevels(logs, tt.k); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopLevels(%d) = %v, want %v", tt.k, got, tt.want)
+			}
+		})
+	}
+
+	if got := TopLevels(nil, 2); got == nil || len(got) != 0 {
+		t.Errorf("TopLevels(nil, 2) = %#v, want an empty non-nil slice", got)
+	}
+}
+
+func TestErrorLogsChunked(t *testing.T) {
+	logs := []LogEntry{
+		{"ERROR", "db"}, {"INFO", "api"}, {"ERROR", "api"}, {"DEBUG", "cache"}, {"error", "cache"}, {"WARN", "db"},
+	}
+	want := []LogEntry{{"ERROR", "db"}, {"ERROR", "api"}, {"error", "cache"}}
+
+	for _, size := range []int{1, 2, 4, 10} {
+		if got := errorLogsChunked(logs, size); !reflect.DeepEqual(got, want) {
+			t.Errorf("errorLogsChunked(size=%d) = %v, want %v", size, got, want)
+		}
+	}
+	if got := errorLogsChunked(nil, 2); len(got) != 0 {
+		t.Errorf("errorLogsChunked(nil) = %v, want empty", got)
+	}
+}
+
+func TestRunLengthLevelsNormalizes(t *testing.T) {
+	logs := []LogEntry{{Level: "info"}, {Level: "INFO"}, {Level: " INFO"}, {Level: "Error"}, {Level: "info"}}
+	want := []LevelCount{{"INFO", 3}, {"ERROR", 1}, {"INFO", 1}}
+
+	if got := runLengthLevels(logs); !reflect.DeepEqual(got, want) {
+		t.Errorf("runLengthLevels = %v, want %v", got, want)
+	}
+}
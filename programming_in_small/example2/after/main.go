@@ -2,13 +2,19 @@ package main
 
 import (
 	"fmt"
+
+	"hard-work/programming_in_small/corecursive"
 )
 
 type APIResponse struct {
-	Items  []string
-	Cursor string
+	Items      []string
+	Cursor     string
+	PrevCursor string
 }
 
+// Сколько страниц может лежать в буфере предзагрузки
+const prefetchBuffer = 2
+
 func fetchAPI(cursor string) APIResponse {
 	// Мокаем API-ответ
 	if cursor == "end" {
@@ -20,6 +26,14 @@ func fetchAPI(cursor string) APIResponse {
 	}
 }
 
+func fetchAPIBackward(cursor string) APIResponse {
+	// Мокаем API, которое отдаёт страницы с конца
+	if cursor == "" {
+		return APIResponse{Items: []string{"item3", "item4"}, PrevCursor: "start"}
+	}
+	return APIResponse{Items: []string{"item1", "item2"}}
+}
+
 // Рекурсивный сбор всех элементов с пагинацией
 func getAllItemsCorecursive(cursor string, acc []string) []string {
 	resp := fetchAPI(cursor)
@@ -30,6 +44,92 @@ func getAllItemsCorecursive(cursor string, acc []string) []string {
 	return getAllItemsCorecursive(resp.Cursor, acc)
 }
 
+// Обход с прогрессом: после каждой страницы - сколько уникальных элементов уже встретилось
+func getAllItemsWithProgress(fetch func(string) APIResponse, cursor string) ([]string, []int) {
+	return walkWithProgress(fetch, cursor, make(map[string]struct{}), []string{}, nil)
+}
+
+func walkWithProgress(fetch func(string) APIResponse, cursor string, seen map[string]struct{}, items []string, progress []int) ([]string, []int) {
+	resp := fetch(cursor)
+	for _, item := range resp.Items {
+		seen[item] = struct{}{}
+	}
+	items = append(items, resp.Items...)
+	progress = append(progress, len(seen))
+	if resp.Cursor == "" {
+		return items, progress
+	}
+	return walkWithProgress(fetch, resp.Cursor, seen, items, progress)
+}
+
+// Тот же обход, но сначала собираются страницы, а элементы получаются через FlatMap
+func getAllItemsFlat(cursor string) []string {
+	return corecursive.FlatMap(fetchPages(cursor, nil), func(page APIResponse) []string {
+		return page.Items
+	})
+}
+
+func fetchPages(cursor string, acc []APIResponse) []APIResponse {
+	resp := fetchAPI(cursor)
+	acc = append(acc, resp)
+	if resp.Cursor == "" {
+		return acc
+	}
+	return fetchPages(resp.Cursor, acc)
+}
+
+// Следующая страница загружается в горутине, пока текущая добавляется в результат.
+// Курсор известен только из предыдущего ответа, поэтому страницы идут строго по порядку
+func getAllItemsPrefetched(fetch func(string) APIResponse, cursor string) []string {
+	pages := make(chan APIResponse, prefetchBuffer)
+	go prefetch(fetch, cursor, pages)
+	return collectPages(pages, []string{})
+}
+
+func prefetch(fetch func(string) APIResponse, cursor string, pages chan<- APIResponse) {
+	resp := fetch(cursor)
+	pages <- resp
+	if resp.Cursor == "" {
+		close(pages)
+		return
+	}
+	prefetch(fetch, resp.Cursor, pages)
+}
+
+func collectPages(pages <-chan APIResponse, acc []string) []string {
+	resp, ok := <-pages
+	if !ok {
+		return acc
+	}
+	return collectPages(pages, append(acc, resp.Items...))
+}
+
+// Обход с последней страницы по PrevCursor, результат - в прямом порядке:
+// каждая следующая (более ранняя) страница ставится перед уже собранными
+func GetAllItemsReverse(fetch func(string) APIResponse) []string {
+	return walkBackward(fetch, "", []string{})
+}
+
+func walkBackward(fetch func(string) APIResponse, cursor string, acc []string) []string {
+	resp := fetch(cursor)
+	acc = append(append(make([]string, 0, len(resp.Items)+len(acc)), resp.Items...), acc...)
+	if resp.PrevCursor == "" {
+		return acc
+	}
+	return walkBackward(fetch, resp.PrevCursor, acc)
+}
+
+// Страницы могут пересекаться, если данные сдвигаются между запросами
+func getAllUniqueItems(cursor string) []string {
+	return corecursive.Dedupe(getAllItemsCorecursive(cursor, []string{}))
+}
+
 func main() {
 	fmt.Println(getAllItemsCorecursive("", []string{}))
-}
\ No newline at end of file
+	fmt.Println(getAllItemsPrefetched(fetchAPI, ""))
+	fmt.Println(getAllItemsFlat(""))
+	fmt.Println(getAllUniqueItems(""))
+	fmt.Println(getAllItemsWithProgress(fetchAPI, ""))
+	fmt.Println(GetAllItemsReverse(fetchAPIBackward))
+	fmt.Println(corecursive.Intersperse(GetAllItemsReverse(fetchAPIBackward), "|"))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Мок API из n страниц по два элемента: p0 -> p1 -> ... -> p(n-1)
+func pagedAPI(n int) func(string) APIResponse {
+	return func(cursor string) APIResponse {
+		page := 0
+		if cursor != "" {
+			fmt.Sscanf(cursor, "p%d", &page)
+		}
+		resp := APIResponse{Items: []string{fmt.Sprintf("item%d-a", page), fmt.Sprintf("item%d-b", page)}}
+		if page+1 < n {
+			resp.Cursor = fmt.Sprintf("p%d", page+1)
+		}
+		return resp
+	}
+}
+
+// Запускать и с -race: страницы передаются из горутины предзагрузки
+func TestGetAllItemsPrefetchedMatchesSequential(t *testing.T) {
+	fetch := pagedAPI(3*prefetchBuffer + 1)
+	want, _ := getAllItemsWithProgress(fetch, "")
+	if len(want) != 2*(3*prefetchBuffer+1) {
+		t.Fatalf("sequential walk returned %d items, want %d", len(want), 2*(3*prefetchBuffer+1))
+	}
+
+	for i := 0; i < 100; i++ {
+		if got := getAllItemsPrefetched(fetch, ""); !reflect.DeepEqual(got, want) {
+			t.Fatalf("getAllItemsPrefetched = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGetAllItemsReverse(t *testing.T) {
+	pages := map[string]APIResponse{
+		"":   {Items: []string{"e", "f"}, PrevCursor: "p2"},
+		"p2": {Items: []string{"c", "d"}, PrevCursor: "p1"},
+		"p1": {Items: []string{"a", "b"}},
+	}
+	var requested []string
+	fetch := func(cursor string) APIResponse {
+		requested = append(requested, cursor)
+		return pages[cursor]
+	}
+
+	if got, want := GetAllItemsReverse(fetch), []string{"a", "b", "c", "d", "e", "f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAllItemsReverse = %v, want %v", got, want)
+	}
+	if want := []string{"", "p2", "p1"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested cursors = %v, want %v", requested, want)
+	}
+}
+
+func TestGetAllItemsWithProgressOverlappingPages(t *testing.T) {
+	pages := map[string]APIResponse{
+		"":   {Items: []string{"a", "b"}, Cursor: "c1"},
+		"c1": {Items: []string{"b", "c"}, Cursor: "c2"},
+		"c2": {Items: []string{"c", "a"}, Cursor: "c3"},
+		"c3": {Items: []string{"d"}},
+	}
+	fetch := func(cursor string) APIResponse { return pages[cursor] }
+
+	items, progress := getAllItemsWithProgress(fetch, "")
+	if want := []string{"a", "b", "b", "c", "c", "a", "d"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %v, want %v", items, want)
+	}
+	if want := []int{2, 3, 3, 4}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+}
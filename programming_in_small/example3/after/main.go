@@ -1,45 +1,1177 @@
 package main
 
 import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
 	"sort"
+	"sync"
+	"time"
+
+	"hard-work/programming_in_small/corecursive"
 )
 
+var ErrInvalidBucketSize = errors.New("bucket size must be positive")
+
 type Event struct {
-	PlayerID string
-	Score    int
+	ID        string
+	PlayerID  string
+	Score     int
+	Timestamp time.Time
+}
+
+type AnnotatedEvent struct {
+	Seq int
+	Event
 }
 
 type Player struct {
+	ID     string
+	Score  int
+	Rank   int
+	Events int
+}
+
+type rankedPlayer struct {
+	ID    string `json:"id"`
+	Score int    `json:"score"`
+	Rank  int    `json:"rank"`
+}
+
+type RankGroup struct {
+	Rank    int
+	Players []Player
+}
+
+type TeamResult struct {
+	Score   int
+	Players []Player
+}
+
+type PlayerShare struct {
 	ID    string
 	Score int
+	Share float64
+}
+
+// До этого числа событий линейный поиск по срезу дешевле, чем заводить map
+const smallLeaderboardThreshold = 32
+
+// Команда для игроков, которым team не сопоставил команду
+const unassignedTeam = "UNASSIGNED"
+
+type PlayerDelta struct {
+	ID          string
+	ScoreChange int
+	RankChange  int
+}
+
+// Таблица, которая хранит итоги между пачками и досчитывает только новые события
+type Leaderboard struct {
+	totals map[string]Player
+	seen   map[string]struct{}
+}
+
+func NewLeaderboard() *Leaderboard {
+	return &Leaderboard{totals: make(map[string]Player), seen: make(map[string]struct{})}
+}
+
+// ApplyBatch добавляет к итогам пачку событий и сортирует таблицу один раз.
+// Повторы по ID отбрасываются и между пачками
+func (l *Leaderboard) ApplyBatch(events []Event) []Player {
+	l.apply(events)
+	return standings(l.totals)
+}
+
+func (l *Leaderboard) apply(remaining []Event) {
+	if len(remaining) == 0 {
+		return
+	}
+
+	if id := remaining[0].ID; id != "" {
+		if _, ok := l.seen[id]; ok {
+			l.apply(remaining[1:])
+			return
+		}
+		l.seen[id] = struct{}{}
+	}
+	addEvent(l.totals, remaining[0])
+	l.apply(remaining[1:])
 }
 
 // Обновление статистики лидеров с использованием корекурсии
 func updateLeaderboardCorecursive(events []Event) []Player {
-	return update(events, make(map[string]int))
+	return update(DedupeEvents(events), make(map[string]Player))
 }
 
-func update(remaining []Event, acc map[string]int) []Player {
+func update(remaining []Event, acc map[string]Player) []Player {
 	if len(remaining) != 0 {
-		acc[remaining[0].PlayerID] += remaining[0].Score
+		addEvent(acc, remaining[0])
 		return update(remaining[1:], acc)
 	}
 
-	var sortedLeaderboard []Player
-	for id, score := range acc {
-		sortedLeaderboard = append(sortedLeaderboard, Player{ID: id, Score: score})
+	return standings(acc)
+}
+
+func addEvent(acc map[string]Player, event Event) {
+	player := acc[event.PlayerID]
+	player.ID = event.PlayerID
+	player.Score += event.Score
+	player.Events++
+	acc[player.ID] = player
+}
+
+// Сортировка накопленных очков по убыванию, при равенстве - по ID, чтобы ранги были стабильными
+func standings(acc map[string]Player) []Player {
+	sortedLeaderboard := make([]Player, 0, len(acc))
+	for _, player := range acc {
+		sortedLeaderboard = append(sortedLeaderboard, player)
 	}
-	sort.Slice(sortedLeaderboard, func(i, j int) bool {
-		return sortedLeaderboard[i].Score > sortedLeaderboard[j].Score
-	})
-	
+	sortPlayers(sortedLeaderboard)
+	for i := range sortedLeaderboard {
+		sortedLeaderboard[i].Rank = i + 1
+	}
+
 	return sortedLeaderboard
 }
 
+func sortPlayers(players []Player) {
+	sort.Slice(players, func(i, j int) bool {
+		return ahead(players[i], players[j])
+	})
+}
+
+// Таблица по возрастанию очков, ранги остаются от основной таблицы
+func ascendingLeaderboard(events []Event) []Player {
+	return corecursive.Reverse(updateLeaderboardCorecursive(events))
+}
+
+// Та же таблица, что и updateLeaderboardCorecursive, но для коротких входов без map:
+// и повторы, и игроки ищутся линейным проходом по уже собранному
+func SmallLeaderboard(events []Event) []Player {
+	if len(events) >= smallLeaderboardThreshold {
+		return updateLeaderboardCorecursive(events)
+	}
+
+	players := accumulateSmall(events, 0, make([]Player, 0, len(events)))
+	sortPlayers(players)
+	for i := range players {
+		players[i].Rank = i + 1
+	}
+	return players
+}
+
+func accumulateSmall(events []Event, next int, acc []Player) []Player {
+	if next == len(events) {
+		return acc
+	}
+
+	event := events[next]
+	if event.ID != "" && seenBefore(events[:next], event.ID) {
+		return accumulateSmall(events, next+1, acc)
+	}
+	for i := range acc {
+		if acc[i].ID == event.PlayerID {
+			acc[i].Score += event.Score
+			acc[i].Events++
+			return accumulateSmall(events, next+1, acc)
+		}
+	}
+	return accumulateSmall(events, next+1, append(acc, Player{ID: event.PlayerID, Score: event.Score, Events: 1}))
+}
+
+func seenBefore(earlier []Event, id string) bool {
+	return corecursive.Any(earlier, func(e Event) bool { return e.ID == id })
+}
+
+// Таблица только для playerIDs, ранги считаются внутри этого набора.
+// Игрок из списка без событий попадает в таблицу с нулём очков
+func LeaderboardFor(events []Event, playerIDs []string) []Player {
+	acc := make(map[string]Player, len(playerIDs))
+	for _, id := range playerIDs {
+		acc[id] = Player{ID: id}
+	}
+	return update(onlyPlayers(DedupeEvents(events), acc, nil), acc)
+}
+
+func onlyPlayers(remaining []Event, allowed map[string]Player, acc []Event) []Event {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	if _, ok := allowed[remaining[0].PlayerID]; ok {
+		acc = append(acc, remaining[0])
+	}
+	return onlyPlayers(remaining[1:], allowed, acc)
+}
+
+// Доступ к игроку по ID за O(1), агрегация общая с отсортированным списком
+func LeaderboardMap(events []Event) map[string]Player {
+	return index(updateLeaderboardCorecursive(events), make(map[string]Player))
+}
+
+func index(remaining []Player, acc map[string]Player) map[string]Player {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	acc[remaining[0].ID] = remaining[0]
+	return index(remaining[1:], acc)
+}
+
+// Средний счёт игрока за событие, без округления: округлять - задача отображения.
+// Игрок попадает в результат только с хотя бы одним событием, так что деления на ноль нет
+func PlayerAverages(events []Event) map[string]float64 {
+	leaderboard := updateLeaderboardCorecursive(events)
+	result := make(map[string]float64, len(leaderboard))
+	for _, player := range leaderboard {
+		result[player.ID] = float64(player.Score) / float64(player.Events)
+	}
+	return result
+}
+
+// Изменения между двумя срезами таблицы, самые большие по модулю - первыми.
+// Отсутствующий в срезе игрок считается с нулём очков и без ранга, тогда RankChange = 0.
+// RankChange > 0 - игрок поднялся
+func LeaderboardDelta(before, after []Player) []PlayerDelta {
+	prev := index(before, make(map[string]Player))
+	next := index(after, make(map[string]Player))
+
+	deltas := collectDeltas(after, prev, nil)
+	deltas = collectDeltas(absent(before, next, nil), prev, deltas)
+
+	sort.Slice(deltas, func(i, j int) bool {
+		ai, aj := abs(deltas[i].ScoreChange), abs(deltas[j].ScoreChange)
+		if ai != aj {
+			return ai > aj
+		}
+		return deltas[i].ID < deltas[j].ID
+	})
+	return deltas
+}
+
+func collectDeltas(remaining []Player, prev map[string]Player, acc []PlayerDelta) []PlayerDelta {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	current := remaining[0]
+	delta := PlayerDelta{ID: current.ID, ScoreChange: current.Score}
+	if old, ok := prev[current.ID]; ok {
+		delta.ScoreChange -= old.Score
+		if current.Rank != 0 {
+			delta.RankChange = old.Rank - current.Rank
+		}
+	}
+	return collectDeltas(remaining[1:], prev, append(acc, delta))
+}
+
+// Игроки, выбывшие из таблицы, - с нулём очков и без ранга
+func absent(remaining []Player, present map[string]Player, acc []Player) []Player {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	if _, ok := present[remaining[0].ID]; !ok {
+		acc = append(acc, Player{ID: remaining[0].ID})
+	}
+	return absent(remaining[1:], present, acc)
+}
+
+// Новые события добавляются к очкам prev, deltas - изменение ранга (> 0 - поднялся).
+// Новичок считается пришедшим с позиции сразу под последним игроком prev
+func ApplyAndDiff(prev []Player, events []Event) (current []Player, deltas map[string]int) {
+	current = update(DedupeEvents(events), index(prev, make(map[string]Player)))
+	return current, rankDeltas(current, index(prev, make(map[string]Player)), len(prev)+1, make(map[string]int, len(current)))
+}
+
+func rankDeltas(remaining []Player, prev map[string]Player, entryRank int, acc map[string]int) map[string]int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	oldRank := entryRank
+	if old, ok := prev[remaining[0].ID]; ok {
+		oldRank = old.Rank
+	}
+	acc[remaining[0].ID] = oldRank - remaining[0].Rank
+	return rankDeltas(remaining[1:], prev, entryRank, acc)
+}
+
+// Насколько перемешалась таблица: сумма модулей изменения рангов, делённая на максимум
+// для перестановки из N игроков (N*N/2, достигается полным разворотом). N - все игроки обоих срезов.
+// Игрок, который есть только в одном срезе, добавляет N-1 как самое дальнее перемещение,
+// поэтому итог ограничен сверху единицей
+func LeaderboardVolatility(before, after []Player) float64 {
+	prev := index(before, make(map[string]Player))
+	next := index(after, make(map[string]Player))
+	dropped := absent(before, next, nil)
+	n := len(after) + len(dropped)
+	if n < 2 {
+		return 0
+	}
+
+	moved := rankShift(after, prev, n, 0) + (n-1)*len(dropped)
+	return min(float64(moved)/float64(n*n/2), 1)
+}
+
+func rankShift(remaining []Player, prev map[string]Player, n, acc int) int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	shift := n - 1
+	if old, ok := prev[remaining[0].ID]; ok {
+		shift = abs(old.Rank - remaining[0].Rank)
+	}
+	return rankShift(remaining[1:], prev, n, acc+shift)
+}
+
+// Игроки, у которых очки были положительными, а стали <= 0. Кого нет в одном из срезов, пропускаем
+func ZeroedPlayers(before, after []Player) []string {
+	zeroed := collectZeroed(after, index(before, make(map[string]Player)), nil)
+	sort.Strings(zeroed)
+	return zeroed
+}
+
+func collectZeroed(remaining []Player, prev map[string]Player, acc []string) []string {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	if old, ok := prev[remaining[0].ID]; ok && old.Score > 0 && remaining[0].Score <= 0 {
+		acc = append(acc, remaining[0].ID)
+	}
+	return collectZeroed(remaining[1:], prev, acc)
+}
+
+// Медиана очков по уже агрегированной таблице, для чётного числа игроков - среднее двух средних
+func MedianScore(players []Player) (float64, bool) {
+	if len(players) == 0 {
+		return 0, false
+	}
+
+	scores := collectScores(players, make([]int, 0, len(players)))
+	sort.Ints(scores)
+	mid := len(scores) / 2
+	if len(scores)%2 == 0 {
+		return float64(scores[mid-1]+scores[mid]) / 2, true
+	}
+	return float64(scores[mid]), true
+}
+
+// Стандартное отклонение очков по генеральной совокупности.
+// Суммы копятся во float64, чтобы квадраты больших очков не переполняли int
+func ScoreStdDev(players []Player) (float64, bool) {
+	if len(players) == 0 {
+		return 0, false
+	}
+
+	n := float64(len(players))
+	mean := sumScores(players, 0) / n
+	return math.Sqrt(sumSquaredDeviations(players, mean, 0) / n), true
+}
+
+func sumScores(remaining []Player, acc float64) float64 {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return sumScores(remaining[1:], acc+float64(remaining[0].Score))
+}
+
+func sumSquaredDeviations(remaining []Player, mean, acc float64) float64 {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	d := float64(remaining[0].Score) - mean
+	return sumSquaredDeviations(remaining[1:], mean, acc+d*d)
+}
+
+// Коэффициент Джини по очкам: 0 - все равны, ближе к 1 - очки у немногих.
+// Отрицательные очки считаются нулём, все нули дают 0
+func ScoreGini(players []Player) (float64, bool) {
+	if len(players) == 0 {
+		return 0, false
+	}
+
+	scores := collectScores(players, make([]int, 0, len(players)))
+	for i := range scores {
+		scores[i] = max(scores[i], 0)
+	}
+	sort.Ints(scores)
+
+	total, weighted := 0.0, 0.0
+	for i, score := range scores {
+		total += float64(score)
+		weighted += float64(i+1) * float64(score)
+	}
+	if total == 0 {
+		return 0, true
+	}
+
+	n := float64(len(scores))
+	return 2*weighted/(n*total) - (n+1)/n, true
+}
+
+// Процентиль игрока: доля игроков со строго меньшим счётом, в процентах
+func PercentileRank(players []Player, id string) (float64, bool) {
+	player, ok := index(players, make(map[string]Player))[id]
+	if !ok {
+		return 0, false
+	}
+
+	lower := corecursive.Count(players, func(p Player) bool { return p.Score < player.Score })
+	return float64(lower) * 100 / float64(len(players)), true
+}
+
+func collectScores(remaining []Player, acc []int) []int {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return collectScores(remaining[1:], append(acc, remaining[0].Score))
+}
+
+// Гистограмма итогов игроков: нижняя граница корзины -> число игроков.
+// Границы кратны bucketSize и округляются вниз, так что -30 при размере 100 попадает в -100
+func ScoreHistogram(events []Event, bucketSize int) (map[int]int, error) {
+	if bucketSize <= 0 {
+		return nil, ErrInvalidBucketSize
+	}
+	return bucket(updateLeaderboardCorecursive(events), bucketSize, make(map[int]int)), nil
+}
+
+func bucket(remaining []Player, size int, acc map[int]int) map[int]int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	lower := remaining[0].Score / size * size
+	if remaining[0].Score%size < 0 {
+		lower -= size
+	}
+	acc[lower]++
+	return bucket(remaining[1:], size, acc)
+}
+
+// Игроки, вошедшие в топ-n current, которых не было в топ-n prev, в порядке current.
+// Если игроков меньше n, топом считается вся таблица
+func NewlyRanked(prev, current []Player, n int) []string {
+	wasTop := index(topN(prev, n), make(map[string]Player))
+	return collectNewcomers(topN(current, n), wasTop, nil)
+}
+
+func topN(players []Player, n int) []Player {
+	return players[:min(max(n, 0), len(players))]
+}
+
+func collectNewcomers(remaining []Player, wasTop map[string]Player, acc []string) []string {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	if _, ok := wasTop[remaining[0].ID]; !ok {
+		acc = append(acc, remaining[0].ID)
+	}
+	return collectNewcomers(remaining[1:], wasTop, acc)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Лучший игрок раунда: таблица уже отсортирована по очкам, а при равенстве - по ID
+func TopGainer(events []Event) (Player, bool) {
+	leaderboard := updateLeaderboardCorecursive(events)
+	if len(leaderboard) == 0 {
+		return Player{}, false
+	}
+	return leaderboard[0], true
+}
+
+// Доля игрока в процентах от суммы положительных итогов игроков.
+// Игрок с итогом <= 0 ничего не вносит и получает 0, поэтому доли не бывают отрицательными
+// и в сумме дают 100. Если положительных итогов нет, результат пустой
+func PlayerContributions(events []Event) map[string]float64 {
+	leaderboard := updateLeaderboardCorecursive(events)
+	total := positiveTotal(leaderboard, 0)
+	if total == 0 {
+		return map[string]float64{}
+	}
+
+	result := make(map[string]float64, len(leaderboard))
+	for _, player := range leaderboard {
+		result[player.ID] = float64(max(player.Score, 0)) * 100 / float64(total)
+	}
+	return result
+}
+
+func positiveTotal(remaining []Player, acc int) int {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return positiveTotal(remaining[1:], acc+max(remaining[0].Score, 0))
+}
+
+// Игроки, чьё последнее событие старше cutoff, по возрастанию ID.
+// Игрок без событий с отметкой времени тоже считается неактивным
+func StalePlayers(events []Event, cutoff time.Time) []string {
+	lastSeen := latest(DedupeEvents(events), make(map[string]time.Time))
+
+	var stale []string
+	for id, seen := range lastSeen {
+		if seen.Before(cutoff) {
+			stale = append(stale, id)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+func latest(remaining []Event, acc map[string]time.Time) map[string]time.Time {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	event := remaining[0]
+	if seen, ok := acc[event.PlayerID]; !ok || event.Timestamp.After(seen) {
+		acc[event.PlayerID] = event.Timestamp
+	}
+	return latest(remaining[1:], acc)
+}
+
+// Самая длинная серия подряд идущих положительных событий игрока в порядке входа.
+// Серию прерывает только событие того же игрока, игрок без положительных событий получает 0
+func LongestStreak(events []Event) map[string]int {
+	return streaks(DedupeEvents(events), make(map[string]int), make(map[string]int))
+}
+
+func streaks(remaining []Event, current, longest map[string]int) map[string]int {
+	if len(remaining) == 0 {
+		return longest
+	}
+
+	id := remaining[0].PlayerID
+	if remaining[0].Score > 0 {
+		current[id]++
+	} else {
+		current[id] = 0
+	}
+	longest[id] = max(longest[id], current[id])
+	return streaks(remaining[1:], current, longest)
+}
+
+// Итоги команд с разбивкой по игрокам. team возвращает "" для игрока без команды.
+// Игроки берутся из общей таблицы, поэтому внутри команды порядок и ранги - общие
+func TeamLeaderboard(events []Event, team func(playerID string) string) map[string]TeamResult {
+	members := corecursive.PartitionBy(updateLeaderboardCorecursive(events), func(p Player) string {
+		if id := team(p.ID); id != "" {
+			return id
+		}
+		return unassignedTeam
+	})
+
+	result := make(map[string]TeamResult, len(members))
+	for id, players := range members {
+		score := 0
+		for _, p := range players {
+			score += p.Score
+		}
+		result[id] = TeamResult{Score: score, Players: players}
+	}
+	return result
+}
+
+// Игроки каждой команды по убыванию доли (в процентах) в сумме положительных итогов команды.
+// Как в PlayerContributions, игрок с итогом <= 0 получает 0, поэтому доли не отрицательны
+// и не больше 100. Если положительных итогов в команде нет, доли всех её игроков равны 0
+func TeamContributionLeaderboard(events []Event, team func(string) string) map[string][]PlayerShare {
+	teams := TeamLeaderboard(events, team)
+	result := make(map[string][]PlayerShare, len(teams))
+	for id, t := range teams {
+		shares := toShares(t.Players, positiveTotal(t.Players, 0), make([]PlayerShare, 0, len(t.Players)))
+		sort.SliceStable(shares, func(i, j int) bool { return shares[i].Share > shares[j].Share })
+		result[id] = shares
+	}
+	return result
+}
+
+func toShares(remaining []Player, total int, acc []PlayerShare) []PlayerShare {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	share := PlayerShare{ID: remaining[0].ID, Score: remaining[0].Score}
+	if total != 0 {
+		share.Share = float64(max(remaining[0].Score, 0)) * 100 / float64(total)
+	}
+	return toShares(remaining[1:], total, append(acc, share))
+}
+
+// Первая и последняя позиция игрока во входном срезе, для одного события они совпадают
+func FirstLastByPlayer(events []Event) map[string][2]int {
+	return firstLast(events, 0, make(map[string][2]int))
+}
+
+func firstLast(remaining []Event, pos int, acc map[string][2]int) map[string][2]int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	span, ok := acc[remaining[0].PlayerID]
+	if !ok {
+		span[0] = pos
+	}
+	span[1] = pos
+	acc[remaining[0].PlayerID] = span
+	return firstLast(remaining[1:], pos+1, acc)
+}
+
+// Общая сумма очков после каждого события, по всем игрокам. Повторно доставленные события
+// отбрасываются через DedupeEvents, как в таблице, поэтому последняя сумма равна сумме итогов игроков,
+// а значений может быть меньше, чем событий на входе
+func PrefixSums(events []Event) []int {
+	deduped := DedupeEvents(events)
+	return prefixSums(deduped, 0, make([]int, 0, len(deduped)))
+}
+
+func prefixSums(remaining []Event, total int, acc []int) []int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	total += remaining[0].Score
+	return prefixSums(remaining[1:], total, append(acc, total))
+}
+
+// Есть ли в пачке игрок с несколькими событиями. Это проверка входа, а не агрегация,
+// поэтому повторы событий по ID здесь тоже считаются
+func HasDuplicatePlayers(events []Event) bool {
+	return hasDuplicate(events, make(map[string]struct{}, len(events)))
+}
+
+func hasDuplicate(remaining []Event, seen map[string]struct{}) bool {
+	if len(remaining) == 0 {
+		return false
+	}
+
+	if _, ok := seen[remaining[0].PlayerID]; ok {
+		return true
+	}
+	seen[remaining[0].PlayerID] = struct{}{}
+	return hasDuplicate(remaining[1:], seen)
+}
+
+// Поочерёдное слияние потоков: по одному событию из каждого, закончившиеся потоки пропускаются.
+// На итоговую таблицу порядок не влияет, он нужен для воспроизводимого повтора событий
+func InterleaveEvents(streams ...[]Event) []Event {
+	total := 0
+	for _, s := range streams {
+		total += len(s)
+	}
+	return interleave(streams, make([]Event, 0, total))
+}
+
+func interleave(streams [][]Event, acc []Event) []Event {
+	var rest [][]Event
+	for _, s := range streams {
+		if len(s) == 0 {
+			continue
+		}
+		acc = append(acc, s[0])
+		if len(s) > 1 {
+			rest = append(rest, s[1:])
+		}
+	}
+	if len(rest) == 0 {
+		return acc
+	}
+	return interleave(rest, acc)
+}
+
+// Повторно доставленные события отбрасываются по ID, события без ID сохраняются все
+func DedupeEvents(events []Event) []Event {
+	return dedupeEvents(events, make(map[string]struct{}), make([]Event, 0, len(events)))
+}
+
+func dedupeEvents(remaining []Event, seen map[string]struct{}, acc []Event) []Event {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	id := remaining[0].ID
+	if id == "" {
+		return dedupeEvents(remaining[1:], seen, append(acc, remaining[0]))
+	}
+	if _, ok := seen[id]; ok {
+		return dedupeEvents(remaining[1:], seen, acc)
+	}
+	seen[id] = struct{}{}
+	return dedupeEvents(remaining[1:], seen, append(acc, remaining[0]))
+}
+
+// Таблица после каждого события. standings каждый раз строит новый срез из значений,
+// поэтому дальнейшие шаги не меняют уже сохранённые снимки
+func LeaderboardProgression(events []Event) [][]Player {
+	deduped := DedupeEvents(events)
+	return progress(deduped, make(map[string]Player), make([][]Player, 0, len(deduped)))
+}
+
+func progress(remaining []Event, totals map[string]Player, acc [][]Player) [][]Player {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	addEvent(totals, remaining[0])
+	return progress(remaining[1:], totals, append(acc, standings(totals)))
+}
+
+// JSON-массив {id, score, rank} в порядке таблицы. Порядок полей задаёт структура,
+// недостающие ранги проставляются по позиции
+func MarshalLeaderboard(players []Player) ([]byte, error) {
+	sorted := append([]Player(nil), players...)
+	sortPlayers(sorted)
+	return json.Marshal(toRanked(sorted, make([]rankedPlayer, 0, len(sorted))))
+}
+
+func toRanked(remaining []Player, acc []rankedPlayer) []rankedPlayer {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	rank := remaining[0].Rank
+	if rank == 0 {
+		rank = len(acc) + 1
+	}
+	return toRanked(remaining[1:], append(acc, rankedPlayer{ID: remaining[0].ID, Score: remaining[0].Score, Rank: rank}))
+}
+
+// Игроки с равными очками собраны в одну группу. Ранг группы - 1 + число игроков выше,
+// поэтому после двух вторых мест идёт четвёртое, и этот же ранг получают игроки группы.
+// Внутри группы порядок по ID уже задан сортировкой
+func GroupedLeaderboard(events []Event) []RankGroup {
+	return groupTies(updateLeaderboardCorecursive(events), 0, nil)
+}
+
+func groupTies(remaining []Player, ahead int, acc []RankGroup) []RankGroup {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	n := tieLength(remaining, remaining[0].Score, 0)
+	group := RankGroup{Rank: ahead + 1, Players: withRank(remaining[:n], ahead+1, make([]Player, 0, n))}
+	return groupTies(remaining[n:], ahead+n, append(acc, group))
+}
+
+// Длина начальной серии игроков с очками score
+func tieLength(remaining []Player, score, acc int) int {
+	if len(remaining) == 0 || remaining[0].Score != score {
+		return acc
+	}
+	return tieLength(remaining[1:], score, acc+1)
+}
+
+func withRank(remaining []Player, rank int, acc []Player) []Player {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	player := remaining[0]
+	player.Rank = rank
+	return withRank(remaining[1:], rank, append(acc, player))
+}
+
+// Таблица по событиям из нескольких шардов: каналы сливаются в один,
+// результат готов, когда закрыты все каналы, либо возвращается ошибка отмены ctx
+func LeaderboardFromChannels(ctx context.Context, chans ...<-chan Event) ([]Player, error) {
+	merged := make(chan Event)
+	var wg sync.WaitGroup
+	for _, ch := range chans {
+		wg.Add(1)
+		go func(ch <-chan Event) {
+			defer wg.Done()
+			forward(ctx, ch, merged)
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	events, err := receive(ctx, merged)
+	if err != nil {
+		return nil, err
+	}
+	return updateLeaderboardCorecursive(events), nil
+}
+
+// Таблица по сохранённому в gob срезу []Event
+func LeaderboardFromGob(r io.Reader) ([]Player, error) {
+	var events []Event
+	if err := gob.NewDecoder(r).Decode(&events); err != nil {
+		return nil, fmt.Errorf("decode events from gob: %w", err)
+	}
+	return updateLeaderboardCorecursive(events), nil
+}
+
+// Топ-n из потока: память - итоги по игрокам и куча из n элементов вместо сортировки всех.
+// Чтобы не хранить все ID событий, повторные доставки здесь не отбрасываются
+func TopNStreaming(events <-chan Event, n int) []Player {
+	totals := drain(events, make(map[string]Player))
+
+	worst := &minPlayers{}
+	for _, player := range totals {
+		if worst.Len() < n {
+			heap.Push(worst, player)
+		} else if n > 0 && ahead(player, (*worst)[0]) {
+			(*worst)[0] = player
+			heap.Fix(worst, 0)
+		}
+	}
+
+	top := make([]Player, worst.Len())
+	for i := len(top) - 1; i >= 0; i-- {
+		top[i] = heap.Pop(worst).(Player)
+	}
+	for i := range top {
+		top[i].Rank = i + 1
+	}
+	return top
+}
+
+func drain(events <-chan Event, acc map[string]Player) map[string]Player {
+	for event := range events {
+		addEvent(acc, event)
+	}
+	return acc
+}
+
+// Порядок таблицы: больше очков выше, при равенстве - меньший ID
+func ahead(a, b Player) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.ID < b.ID
+}
+
+// Куча, на вершине которой худший из отобранных игроков
+type minPlayers []Player
+
+func (h minPlayers) Len() int           { return len(h) }
+func (h minPlayers) Less(i, j int) bool { return ahead(h[j], h[i]) }
+func (h minPlayers) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minPlayers) Push(x any)        { *h = append(*h, x.(Player)) }
+func (h *minPlayers) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// Таблица после каждого события из потока. Повторы отбрасываются по ID, как в DedupeEvents.
+// standings строит новый срез на каждое событие, так что получатель может его менять.
+// Канал закрывается, когда закрыт events или отменён ctx
+func ScanLeaderboard(ctx context.Context, events <-chan Event) <-chan []Player {
+	out := make(chan []Player)
+	go func() {
+		defer close(out)
+		scan(ctx, events, out)
+	}()
+	return out
+}
+
+func scan(ctx context.Context, events <-chan Event, out chan<- []Player) {
+	seen := make(map[string]struct{})
+	totals := make(map[string]Player)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.ID != "" {
+				if _, dup := seen[event.ID]; dup {
+					continue
+				}
+				seen[event.ID] = struct{}{}
+			}
+			addEvent(totals, event)
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- standings(totals):
+			}
+		}
+	}
+}
+
+// Равномерная выборка до k событий из потока неизвестной длины (Algorithm R).
+// i-е событие (с нуля) заменяет случайный элемент выборки с вероятностью k/(i+1)
+func ReservoirSample(events <-chan Event, k int, rng *rand.Rand) []Event {
+	if k <= 0 {
+		// дочитываем поток, чтобы не заблокировать отправителя
+		for range events {
+		}
+		return nil
+	}
+
+	acc := make([]Event, 0, k)
+	seen := 0
+	for event := range events {
+		if seen < k {
+			acc = append(acc, event)
+		} else if j := rng.Intn(seen + 1); j < k {
+			acc[j] = event
+		}
+		seen++
+	}
+	return acc
+}
+
+func forward(ctx context.Context, in <-chan Event, out chan<- Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-in:
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- event:
+			}
+		}
+	}
+}
+
+func receive(ctx context.Context, in <-chan Event) ([]Event, error) {
+	var acc []Event
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case event, ok := <-in:
+			if !ok {
+				// при отмене merged тоже закрывается, и select мог выбрать эту ветку
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				return acc, nil
+			}
+			acc = append(acc, event)
+		}
+	}
+}
+
+// Делят ли первое место несколько игроков, и ID всех игроков с лучшим счётом по возрастанию
+func HasTiedWinner(players []Player) (bool, []string) {
+	top, ok := corecursive.MaxBy(players, playerScore)
+	if !ok {
+		return false, nil
+	}
+
+	var winners []string
+	for _, p := range players {
+		if p.Score == top.Score {
+			winners = append(winners, p.ID)
+		}
+	}
+	sort.Strings(winners)
+	return len(winners) > 1, winners
+}
+
+// Лидер и аутсайдер таблицы
+func topAndBottom(players []Player) (top, bottom Player, ok bool) {
+	top, ok = corecursive.MaxBy(players, playerScore)
+	bottom, _ = corecursive.MinBy(players, playerScore)
+	return top, bottom, ok
+}
+
+func playerScore(p Player) int {
+	return p.Score
+}
+
+// Скользящие суммы очков по окнам из size событий
+func rollingScoreSums(events []Event, size int) ([]int, error) {
+	windows, err := corecursive.SlidingWindow(DedupeEvents(events), size)
+	if err != nil {
+		return nil, err
+	}
+	return sumWindows(windows, make([]int, 0, len(windows))), nil
+}
+
+func sumWindows(remaining [][]Event, acc []int) []int {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return sumWindows(remaining[1:], append(acc, totalScore(remaining[0], 0)))
+}
+
+func totalScore(remaining []Event, acc int) int {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return totalScore(remaining[1:], acc+remaining[0].Score)
+}
+
+// Синтетические события одного игрока с очками 10, 20, 30, ...
+func scoreRamp(playerID string, n int) []Event {
+	return corecursive.Iterate(Event{PlayerID: playerID, Score: 10}, func(e Event) Event {
+		return Event{PlayerID: e.PlayerID, Score: e.Score + 10}
+	}, n)
+}
+
+// Строки таблицы для вывода: "1. player3 (200)"
+func numberedLines(players []Player) []string {
+	return corecursive.MapIndexed(players, func(i int, p Player) string {
+		return fmt.Sprintf("%d. %s (%d)", i+1, p.ID, p.Score)
+	})
+}
+
+// ID и очки таблицы параллельными срезами
+func splitScores(players []Player) ([]string, []int) {
+	return corecursive.Unzip(scorePairs(players, make([]corecursive.Pair[string, int], 0, len(players))))
+}
+
+func scorePairs(remaining []Player, acc []corecursive.Pair[string, int]) []corecursive.Pair[string, int] {
+	if len(remaining) == 0 {
+		return acc
+	}
+	return scorePairs(remaining[1:], append(acc, corecursive.Pair[string, int]{First: remaining[0].ID, Second: remaining[0].Score}))
+}
+
+// Ранг игрока после каждого события. Пока у игрока нет событий, он сразу под последним в таблице
+func RankTimeline(events []Event, playerID string) []int {
+	snapshots := LeaderboardProgression(events)
+	return rankAfter(snapshots, playerID, make([]int, 0, len(snapshots)))
+}
+
+func rankAfter(remaining [][]Player, playerID string, acc []int) []int {
+	if len(remaining) == 0 {
+		return acc
+	}
+
+	rank := len(remaining[0]) + 1
+	if player, ok := index(remaining[0], make(map[string]Player))[playerID]; ok {
+		rank = player.Rank
+	}
+	return rankAfter(remaining[1:], playerID, append(acc, rank))
+}
+
+// Отпечаток таблицы для проверки изменений: FNV-64a по (id, score, rank) в порядке строк.
+// ID пишется в кавычках, чтобы разные таблицы не склеивались в одинаковые байты
+func LeaderboardHash(players []Player) string {
+	h := fnv.New64a()
+	for _, p := range players {
+		fmt.Fprintf(h, "%q %d %d;", p.ID, p.Score, p.Rank)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Нумерация событий: события склеиваются со срезом порядковых номеров
+func annotateEvents(events []Event, seq []int) []AnnotatedEvent {
+	return corecursive.Zip(events, seq, func(e Event, n int) AnnotatedEvent {
+		return AnnotatedEvent{Seq: n, Event: e}
+	})
+}
+
 func main() {
 	events := []Event{
-		{"player1", 100}, {"player2", 50}, {"player1", -30}, {"player3", 200},
+		{ID: "e1", PlayerID: "player1", Score: 100},
+		{ID: "e2", PlayerID: "player2", Score: 50, Timestamp: time.Now().Add(-48 * time.Hour)},
+		{ID: "e3", PlayerID: "player1", Score: -30, Timestamp: time.Now().Add(-time.Hour)},
+		{ID: "e4", PlayerID: "player3", Score: 200},
+		{ID: "e4", PlayerID: "player3", Score: 200},
 	}
 	fmt.Println(updateLeaderboardCorecursive(events))
+	fmt.Println(LeaderboardMap(events)["player1"])
+	fmt.Println(corecursive.FoldMap(LeaderboardMap(events), 0, func(sum int, _ string, p Player) int {
+		return sum + p.Score
+	}, func(a, b string) bool { return a < b }))
+	fmt.Println(ascendingLeaderboard(events))
+	fmt.Println(SmallLeaderboard(events))
+	fmt.Println(LeaderboardFor(events, []string{"player2", "player1", "friend"}))
+	incremental := NewLeaderboard()
+	incremental.ApplyBatch(events[:2])
+	fmt.Println(incremental.ApplyBatch(events[2:]))
+	fmt.Println(PlayerAverages(events))
+	fmt.Println(PlayerContributions(events))
+	fmt.Println(LongestStreak(events))
+	fmt.Println(FirstLastByPlayer(events))
+	fmt.Println(PrefixSums(events))
+	fmt.Println(InterleaveEvents(events[:3], events[3:]))
+	fmt.Println(HasDuplicatePlayers(events), HasDuplicatePlayers(events[:2]))
+	fmt.Println(TeamLeaderboard(events, teamOf))
+	fmt.Println(TeamContributionLeaderboard(events, teamOf))
+	fmt.Println(corecursive.All(events, func(e Event) bool { return e.Score > 0 }))
+	fmt.Println(rollingScoreSums(events, 2))
+	fmt.Println(StalePlayers(events, time.Now().Add(-24*time.Hour)))
+	fmt.Println(scoreRamp("bot", 3))
+	fmt.Println(annotateEvents(events, []int{1, 2, 3, 4}))
+	fmt.Println(TopGainer(events))
+	fmt.Println(ScoreHistogram(events, 100))
+	fmt.Println(LeaderboardHash(updateLeaderboardCorecursive(events)) == LeaderboardHash(SmallLeaderboard(events)))
+	fmt.Println(numberedLines(updateLeaderboardCorecursive(events)))
+	fmt.Println(splitScores(updateLeaderboardCorecursive(events)))
+	fmt.Println(PercentileRank(updateLeaderboardCorecursive(events), "player1"))
+	fmt.Println(ScoreStdDev(updateLeaderboardCorecursive(events)))
+	fmt.Println(ScoreGini(updateLeaderboardCorecursive(events)))
+	fmt.Println(MedianScore(updateLeaderboardCorecursive(events)))
+	fmt.Println(HasTiedWinner(updateLeaderboardCorecursive(append(events, Event{ID: "e9", PlayerID: "player2", Score: 150}))))
+	fmt.Println(topAndBottom(updateLeaderboardCorecursive(events)))
+	if data, err := MarshalLeaderboard(updateLeaderboardCorecursive(events)); err == nil {
+		fmt.Println(string(data))
+	}
+	fmt.Println(LeaderboardProgression(events))
+	fmt.Println(RankTimeline(events, "player2"))
+	fmt.Println(GroupedLeaderboard(append(events, Event{ID: "e5", PlayerID: "player2", Score: 20})))
+	fmt.Println(LeaderboardFromChannels(context.Background(), shard(events[:2]), shard(events[2:])))
+	fmt.Println(ApplyAndDiff(updateLeaderboardCorecursive(events), []Event{{ID: "e7", PlayerID: "player4", Score: 60}, {ID: "e8", PlayerID: "player2", Score: 100}}))
+	fmt.Println(NewlyRanked(updateLeaderboardCorecursive(events[:3]), updateLeaderboardCorecursive(events), 2))
+	fmt.Println(LeaderboardVolatility(updateLeaderboardCorecursive(events), updateLeaderboardCorecursive(events)))
+	fmt.Println(LeaderboardVolatility(updateLeaderboardCorecursive(events[:3]), updateLeaderboardCorecursive(events)))
+	fmt.Println(ZeroedPlayers(updateLeaderboardCorecursive(events), updateLeaderboardCorecursive(append(events, Event{ID: "e6", PlayerID: "player1", Score: -70}))))
+	fmt.Println(TopNStreaming(shard(DedupeEvents(events)), 2))
+	fmt.Println(ReservoirSample(shard(events), 2, rand.New(rand.NewSource(1))))
+	var live []Player
+	for snapshot := range ScanLeaderboard(context.Background(), shard(events)) {
+		live = snapshot
+	}
+	fmt.Println(live)
+	var stored bytes.Buffer
+	if err := gob.NewEncoder(&stored).Encode(events); err == nil {
+		fmt.Println(LeaderboardFromGob(&stored))
+	}
+	fmt.Println(LeaderboardDelta(updateLeaderboardCorecursive(events[:2]), updateLeaderboardCorecursive(events)))
+}
+
+func shard(events []Event) <-chan Event {
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		for _, event := range events {
+			ch <- event
+		}
+	}()
+	return ch
+}
+
+func teamOf(playerID string) string {
+	return map[string]string{"player1": "red", "player3": "red"}[playerID]
 }
@@ -0,0 +1,949 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// События из main без отметок времени: e4 доставлено дважды
+func sampleEvents() []Event {
+	return []Event{
+		{ID: "e1", PlayerID: "player1", Score: 100},
+		{ID: "e2", PlayerID: "player2", Score: 50},
+		{ID: "e3", PlayerID: "player1", Score: -30},
+		{ID: "e4", PlayerID: "player3", Score: 200},
+		{ID: "e4", PlayerID: "player3", Score: 200},
+	}
+}
+
+func TestLeaderboardMapMatchesSortedEntries(t *testing.T) {
+	events := sampleEvents()
+	byID := LeaderboardMap(events)
+	sorted := updateLeaderboardCorecursive(events)
+
+	if len(byID) != len(sorted) {
+		t.Fatalf("len(LeaderboardMap) = %d, want %d", len(byID), len(sorted))
+	}
+	for _, want := range sorted {
+		if got, ok := byID[want.ID]; !ok || got != want {
+			t.Errorf("LeaderboardMap[%q] = %+v, %v, want %+v", want.ID, got, ok, want)
+		}
+	}
+}
+
+func TestPlayerAverages(t *testing.T) {
+	got := PlayerAverages(sampleEvents())
+
+	tests := []struct {
+		name string
+		id   string
+		want float64
+	}{
+		{name: "multi-event player", id: "player1", want: 35},
+		{name: "single-event player", id: "player2", want: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got[tt.id] != tt.want {
+				t.Errorf("PlayerAverages[%q] = %v, want %v", tt.id, got[tt.id], tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderboardEventCounts(t *testing.T) {
+	events := []Event{
+		{ID: "a1", PlayerID: "a", Score: 1},
+		{ID: "b1", PlayerID: "b", Score: 1},
+		{ID: "a2", PlayerID: "a", Score: 1},
+		{ID: "c1", PlayerID: "c", Score: 1},
+		{ID: "a3", PlayerID: "a", Score: 1},
+		{ID: "b2", PlayerID: "b", Score: 1},
+		{ID: "z1", PlayerID: "zero", Score: 5},
+		{ID: "z2", PlayerID: "zero", Score: -5},
+	}
+	// у zero итог 0, но оба события должны учитываться
+	want := map[string]int{"a": 3, "b": 2, "c": 1, "zero": 2}
+
+	players := updateLeaderboardCorecursive(events)
+	if len(players) != len(want) {
+		t.Fatalf("len(players) = %d, want %d", len(players), len(want))
+	}
+	for _, player := range players {
+		if player.Events != want[player.ID] {
+			t.Errorf("%s.Events = %d, want %d", player.ID, player.Events, want[player.ID])
+		}
+	}
+}
+
+func TestLeaderboardDelta(t *testing.T) {
+	before := []Player{{ID: "a", Score: 100, Rank: 1}, {ID: "b", Score: 50, Rank: 2}}
+	after := []Player{{ID: "b", Score: 200, Rank: 1}, {ID: "a", Score: 100, Rank: 2}, {ID: "c", Score: 10, Rank: 3}}
+	want := []PlayerDelta{
+		{ID: "b", ScoreChange: 150, RankChange: 1},
+		{ID: "c", ScoreChange: 10, RankChange: 0},
+		{ID: "a", ScoreChange: 0, RankChange: -1},
+	}
+
+	if got := LeaderboardDelta(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("LeaderboardDelta = %+v, want %+v", got, want)
+	}
+}
+
+func TestTopGainer(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   Player
+		wantOK bool
+	}{
+		{
+			name:   "clear winner",
+			events: sampleEvents(),
+			want:   Player{ID: "player3", Score: 200, Rank: 1, Events: 1},
+			wantOK: true,
+		},
+		{
+			name:   "tie goes to the smaller ID",
+			events: []Event{{PlayerID: "b", Score: 10}, {PlayerID: "a", Score: 10}},
+			want:   Player{ID: "a", Score: 10, Rank: 1, Events: 1},
+			wantOK: true,
+		},
+		{name: "empty", events: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := TopGainer(tt.events)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("TopGainer = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestDedupeEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   []Event
+	}{
+		{
+			name:   "duplicate IDs keep the first delivery",
+			events: []Event{{ID: "e1", Score: 1}, {ID: "e2", Score: 2}, {ID: "e1", Score: 3}},
+			want:   []Event{{ID: "e1", Score: 1}, {ID: "e2", Score: 2}},
+		},
+		{
+			name:   "unique IDs",
+			events: []Event{{ID: "e1", Score: 1}, {ID: "e2", Score: 2}},
+			want:   []Event{{ID: "e1", Score: 1}, {ID: "e2", Score: 2}},
+		},
+		{
+			name:   "empty IDs are all kept",
+			events: []Event{{Score: 1}, {Score: 1}, {ID: "e1", Score: 2}},
+			want:   []Event{{Score: 1}, {Score: 1}, {ID: "e1", Score: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DedupeEvents(tt.events); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DedupeEvents = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderboardProgression(t *testing.T) {
+	want := [][]Player{
+		{{ID: "player1", Score: 100, Rank: 1, Events: 1}},
+		{{ID: "player1", Score: 100, Rank: 1, Events: 1}, {ID: "player2", Score: 50, Rank: 2, Events: 1}},
+		{{ID: "player1", Score: 70, Rank: 1, Events: 2}, {ID: "player2", Score: 50, Rank: 2, Events: 1}},
+		{
+			{ID: "player3", Score: 200, Rank: 1, Events: 1},
+			{ID: "player1", Score: 70, Rank: 2, Events: 2},
+			{ID: "player2", Score: 50, Rank: 3, Events: 1},
+		},
+	}
+
+	got := LeaderboardProgression(sampleEvents())
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("LeaderboardProgression = %v, want %v", got, want)
+	}
+
+	got[0][0].Score = -1
+	if got[1][0].Score != 100 {
+		t.Errorf("changing one snapshot changed another: %v", got[1])
+	}
+}
+
+func TestMarshalLeaderboard(t *testing.T) {
+	players := updateLeaderboardCorecursive(sampleEvents())
+	data, err := MarshalLeaderboard(players)
+	if err != nil {
+		t.Fatalf("MarshalLeaderboard error = %v", err)
+	}
+
+	want := `[{"id":"player3","score":200,"rank":1},{"id":"player1","score":70,"rank":2},{"id":"player2","score":50,"rank":3}]`
+	if string(data) != want {
+		t.Errorf("MarshalLeaderboard = %s, want %s", data, want)
+	}
+
+	var decoded []rankedPlayer
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal error = %v", err)
+	}
+	for i, p := range players {
+		if r := decoded[i]; r.ID != p.ID || r.Score != p.Score || r.Rank != p.Rank {
+			t.Errorf("decoded[%d] = %+v, want %+v", i, r, p)
+		}
+	}
+}
+
+func TestGroupedLeaderboard(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   []RankGroup
+	}{
+		{
+			name: "tied players share a rank",
+			events: []Event{
+				{PlayerID: "a", Score: 10}, {PlayerID: "c", Score: 20}, {PlayerID: "b", Score: 20}, {PlayerID: "d", Score: 5},
+			},
+			want: []RankGroup{
+				{Rank: 1, Players: []Player{{ID: "b", Score: 20, Rank: 1, Events: 1}, {ID: "c", Score: 20, Rank: 1, Events: 1}}},
+				{Rank: 3, Players: []Player{{ID: "a", Score: 10, Rank: 3, Events: 1}}},
+				{Rank: 4, Players: []Player{{ID: "d", Score: 5, Rank: 4, Events: 1}}},
+			},
+		},
+		{
+			name:   "all distinct",
+			events: sampleEvents(),
+			want: []RankGroup{
+				{Rank: 1, Players: []Player{{ID: "player3", Score: 200, Rank: 1, Events: 1}}},
+				{Rank: 2, Players: []Player{{ID: "player1", Score: 70, Rank: 2, Events: 2}}},
+				{Rank: 3, Players: []Player{{ID: "player2", Score: 50, Rank: 3, Events: 1}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GroupedLeaderboard(tt.events); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupedLeaderboard = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Запускать и с -race: события приходят из нескольких горутин
+func TestLeaderboardFromChannelsMultipleProducers(t *testing.T) {
+	var all []Event
+	var chans []<-chan Event
+	for p := 0; p < 4; p++ {
+		var part []Event
+		for i := 0; i < 50; i++ {
+			part = append(part, Event{ID: fmt.Sprintf("s%d-%d", p, i), PlayerID: fmt.Sprintf("player%d", i%7), Score: p*10 + i})
+		}
+		all = append(all, part...)
+		chans = append(chans, shard(part))
+	}
+
+	got, err := LeaderboardFromChannels(context.Background(), chans...)
+	if err != nil {
+		t.Fatalf("LeaderboardFromChannels error = %v", err)
+	}
+	if want := updateLeaderboardCorecursive(all); !reflect.DeepEqual(got, want) {
+		t.Errorf("LeaderboardFromChannels = %v, want %v", got, want)
+	}
+}
+
+// Производитель, который шлёт события, пока не отменён ctx, и никогда не закрывает канал
+func endless(ctx context.Context, playerID string) <-chan Event {
+	ch := make(chan Event)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- Event{PlayerID: playerID, Score: 1}:
+			}
+		}
+	}()
+	return ch
+}
+
+func TestLeaderboardFromChannelsCanceledMidStream(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		var chans []<-chan Event
+		for p := 0; p < 16; p++ {
+			chans = append(chans, endless(ctx, fmt.Sprintf("player%d", p)))
+		}
+		time.AfterFunc(50*time.Microsecond, cancel)
+
+		got, err := LeaderboardFromChannels(ctx, chans...)
+		if !errors.Is(err, context.Canceled) || got != nil {
+			t.Fatalf("run %d: LeaderboardFromChannels = %v, %v, want nil, %v", i, got, err, context.Canceled)
+		}
+		cancel()
+	}
+}
+
+// После отмены merged закрывается, и select в receive видит сразу обе готовые ветки
+func TestReceiveCanceledAndClosed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	closed := make(chan Event)
+	close(closed)
+
+	for i := 0; i < 100; i++ {
+		if got, err := receive(ctx, closed); !errors.Is(err, context.Canceled) || got != nil {
+			t.Fatalf("receive = %v, %v, want nil, %v", got, err, context.Canceled)
+		}
+	}
+}
+
+func TestLeaderboardFromChannelsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := LeaderboardFromChannels(ctx, make(chan Event)); !errors.Is(err, context.Canceled) {
+		t.Errorf("LeaderboardFromChannels error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestTopAndBottom(t *testing.T) {
+	top, bottom, ok := topAndBottom(updateLeaderboardCorecursive(sampleEvents()))
+	if !ok || top.ID != "player3" || bottom.ID != "player2" {
+		t.Errorf("topAndBottom = %v, %v, %v, want player3, player2, true", top.ID, bottom.ID, ok)
+	}
+	if _, _, ok := topAndBottom(nil); ok {
+		t.Error("topAndBottom on empty standings reported ok")
+	}
+}
+
+func TestZeroedPlayers(t *testing.T) {
+	before := updateLeaderboardCorecursive(sampleEvents())
+
+	tests := []struct {
+		name    string
+		penalty Event
+		want    []string
+	}{
+		{name: "crossing zero", penalty: Event{ID: "p1", PlayerID: "player1", Score: -100}, want: []string{"player1"}},
+		{name: "landing on zero", penalty: Event{ID: "p1", PlayerID: "player2", Score: -50}, want: []string{"player2"}},
+		{name: "staying positive", penalty: Event{ID: "p1", PlayerID: "player3", Score: -150}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := updateLeaderboardCorecursive(append(sampleEvents(), tt.penalty))
+			if got := ZeroedPlayers(before, after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ZeroedPlayers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMedianScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []Player
+		want    float64
+		wantOK  bool
+	}{
+		{name: "odd", players: []Player{{Score: 50}, {Score: 200}, {Score: 70}}, want: 70, wantOK: true},
+		{name: "even", players: []Player{{Score: 10}, {Score: 40}, {Score: 20}, {Score: 100}}, want: 30, wantOK: true},
+		{name: "empty", players: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := MedianScore(tt.players); got != tt.want || ok != tt.wantOK {
+				t.Errorf("MedianScore = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPlayerContributions(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   map[string]float64
+	}{
+		{
+			name:   "positive only",
+			events: []Event{{PlayerID: "a", Score: 30}, {PlayerID: "b", Score: 10}},
+			want:   map[string]float64{"a": 75, "b": 25},
+		},
+		{
+			name:   "mixed with negatives",
+			events: append(sampleEvents(), Event{ID: "e5", PlayerID: "player4", Score: -40}),
+			want:   map[string]float64{"player1": 21.875, "player2": 15.625, "player3": 62.5, "player4": 0},
+		},
+		{
+			name:   "no positive totals",
+			events: []Event{{PlayerID: "a", Score: -5}},
+			want:   map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlayerContributions(tt.events); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlayerContributions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreHistogram(t *testing.T) {
+	events := []Event{
+		{PlayerID: "a", Score: 250}, {PlayerID: "b", Score: 199}, {PlayerID: "c", Score: 0},
+		{PlayerID: "d", Score: -30}, {PlayerID: "e", Score: -100}, {PlayerID: "f", Score: -101},
+	}
+	want := map[int]int{200: 1, 100: 1, 0: 1, -100: 2, -200: 1}
+
+	got, err := ScoreHistogram(events, 100)
+	if err != nil {
+		t.Fatalf("ScoreHistogram error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScoreHistogram = %v, want %v", got, want)
+	}
+	if _, err := ScoreHistogram(events, 0); !errors.Is(err, ErrInvalidBucketSize) {
+		t.Errorf("ScoreHistogram size 0 error = %v, want %v", err, ErrInvalidBucketSize)
+	}
+}
+
+func TestStalePlayers(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	events := []Event{
+		{ID: "e1", PlayerID: "recent", Score: 1, Timestamp: now.Add(-48 * time.Hour)},
+		{ID: "e2", PlayerID: "recent", Score: 1, Timestamp: now.Add(-time.Hour)},
+		{ID: "e3", PlayerID: "old", Score: 1, Timestamp: now.Add(-48 * time.Hour)},
+		{ID: "e4", PlayerID: "untimestamped", Score: 1},
+	}
+
+	got := StalePlayers(events, now.Add(-24*time.Hour))
+	if want := []string{"old", "untimestamped"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StalePlayers = %v, want %v", got, want)
+	}
+}
+
+func TestScoreRamp(t *testing.T) {
+	got := scoreRamp("bot", 3)
+	want := []Event{{PlayerID: "bot", Score: 10}, {PlayerID: "bot", Score: 20}, {PlayerID: "bot", Score: 30}}
+	if len(got) != len(want) {
+		t.Fatalf("scoreRamp = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].PlayerID != want[i].PlayerID || got[i].Score != want[i].Score {
+			t.Errorf("scoreRamp[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLongestStreak(t *testing.T) {
+	events := []Event{
+		{PlayerID: "alternating", Score: 5},
+		{PlayerID: "consecutive", Score: 1},
+		{PlayerID: "alternating", Score: -5},
+		{PlayerID: "consecutive", Score: 2},
+		{PlayerID: "alternating", Score: 5},
+		{PlayerID: "negative", Score: -1},
+		{PlayerID: "consecutive", Score: 3},
+		{PlayerID: "alternating", Score: 0},
+	}
+	want := map[string]int{"alternating": 1, "consecutive": 3, "negative": 0}
+
+	if got := LongestStreak(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("LongestStreak = %v, want %v", got, want)
+	}
+}
+
+func TestApplyAndDiff(t *testing.T) {
+	prev := updateLeaderboardCorecursive(sampleEvents())
+	events := []Event{{ID: "n1", PlayerID: "player2", Score: 200}, {ID: "n2", PlayerID: "player4", Score: 100}}
+
+	current, deltas := ApplyAndDiff(prev, events)
+	wantCurrent := []Player{
+		{ID: "player2", Score: 250, Rank: 1, Events: 2},
+		{ID: "player3", Score: 200, Rank: 2, Events: 1},
+		{ID: "player4", Score: 100, Rank: 3, Events: 1},
+		{ID: "player1", Score: 70, Rank: 4, Events: 2},
+	}
+	wantDeltas := map[string]int{"player2": 2, "player3": -1, "player4": 1, "player1": -2}
+
+	if !reflect.DeepEqual(current, wantCurrent) {
+		t.Errorf("current = %v, want %v", current, wantCurrent)
+	}
+	if !reflect.DeepEqual(deltas, wantDeltas) {
+		t.Errorf("deltas = %v, want %v", deltas, wantDeltas)
+	}
+}
+
+func TestPercentileRank(t *testing.T) {
+	players := updateLeaderboardCorecursive(sampleEvents())
+
+	tests := []struct {
+		name   string
+		id     string
+		want   float64
+		wantOK bool
+	}{
+		{name: "top player", id: "player3", want: 200.0 / 3, wantOK: true},
+		{name: "mid player", id: "player1", want: 100.0 / 3, wantOK: true},
+		{name: "absent ID", id: "nobody"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PercentileRank(players, tt.id)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("PercentileRank(%q) = %v, %v, want %v, %v", tt.id, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLeaderboardFromGob(t *testing.T) {
+	var stored bytes.Buffer
+	if err := gob.NewEncoder(&stored).Encode(sampleEvents()); err != nil {
+		t.Fatalf("gob encode error = %v", err)
+	}
+
+	got, err := LeaderboardFromGob(&stored)
+	if err != nil {
+		t.Fatalf("LeaderboardFromGob error = %v", err)
+	}
+	if want := updateLeaderboardCorecursive(sampleEvents()); !reflect.DeepEqual(got, want) {
+		t.Errorf("LeaderboardFromGob = %v, want %v", got, want)
+	}
+}
+
+func TestLeaderboardFromGobDecodeError(t *testing.T) {
+	got, err := LeaderboardFromGob(bytes.NewReader([]byte("not gob")))
+	if err == nil || got != nil {
+		t.Errorf("LeaderboardFromGob = %v, %v, want nil and an error", got, err)
+	}
+}
+
+// Много игроков с повторяющимися итогами, чтобы порядок решался и по ID
+func manyEvents(players, perPlayer int) []Event {
+	var events []Event
+	for i := 0; i < perPlayer; i++ {
+		for p := 0; p < players; p++ {
+			events = append(events, Event{ID: fmt.Sprintf("e%d-%d", p, i), PlayerID: fmt.Sprintf("p%02d", p), Score: (p*7 + i) % 11})
+		}
+	}
+	return events
+}
+
+func TestTopNStreamingMatchesFullSort(t *testing.T) {
+	events := manyEvents(20, 5)
+	full := updateLeaderboardCorecursive(events)
+
+	for _, n := range []int{0, 1, 5, 20, 25} {
+		t.Run(fmt.Sprintf("n=%d", n), func(t *testing.T) {
+			got := TopNStreaming(shard(events), n)
+			if want := full[:min(n, len(full))]; !reflect.DeepEqual(got, want) {
+				t.Errorf("TopNStreaming(%d) = %v, want %v", n, got, want)
+			}
+		})
+	}
+}
+
+func TestScoreStdDev(t *testing.T) {
+	// среднее 5, квадраты отклонений 9+1+1+1+0+0+4+16 = 32, 32/8 = 4
+	players := []Player{{Score: 2}, {Score: 4}, {Score: 4}, {Score: 4}, {Score: 5}, {Score: 5}, {Score: 7}, {Score: 9}}
+	if got, ok := ScoreStdDev(players); !ok || got != 2 {
+		t.Errorf("ScoreStdDev = %v, %v, want 2, true", got, ok)
+	}
+	if _, ok := ScoreStdDev(nil); ok {
+		t.Error("ScoreStdDev on empty standings reported ok")
+	}
+}
+
+func TestNewlyRanked(t *testing.T) {
+	prev := updateLeaderboardCorecursive(sampleEvents())
+
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{name: "climbing into the top n", event: Event{ID: "n1", PlayerID: "player2", Score: 30}, want: []string{"player2"}},
+		{name: "staying out", event: Event{ID: "n1", PlayerID: "player2", Score: 10}, want: nil},
+		{name: "newcomer entering", event: Event{ID: "n1", PlayerID: "player4", Score: 500}, want: []string{"player4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := updateLeaderboardCorecursive(append(sampleEvents(), tt.event))
+			if got := NewlyRanked(prev, current, 2); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewlyRanked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTeamLeaderboard(t *testing.T) {
+	events := append(sampleEvents(), Event{ID: "e5", PlayerID: "player4", Score: 40})
+	team := func(id string) string {
+		return map[string]string{"player1": "red", "player3": "red", "player4": "blue"}[id]
+	}
+	want := map[string]TeamResult{
+		"red": {Score: 270, Players: []Player{
+			{ID: "player3", Score: 200, Rank: 1, Events: 1},
+			{ID: "player1", Score: 70, Rank: 2, Events: 2},
+		}},
+		"blue":         {Score: 40, Players: []Player{{ID: "player4", Score: 40, Rank: 4, Events: 1}}},
+		unassignedTeam: {Score: 50, Players: []Player{{ID: "player2", Score: 50, Rank: 3, Events: 1}}},
+	}
+
+	if got := TeamLeaderboard(events, team); !reflect.DeepEqual(got, want) {
+		t.Errorf("TeamLeaderboard = %+v, want %+v", got, want)
+	}
+}
+
+func TestRankTimeline(t *testing.T) {
+	events := []Event{
+		{ID: "e1", PlayerID: "leader", Score: 100},
+		{ID: "e2", PlayerID: "climber", Score: 10},
+		{ID: "e3", PlayerID: "other", Score: 50},
+		{ID: "e4", PlayerID: "climber", Score: 60},
+		{ID: "e5", PlayerID: "climber", Score: 60},
+	}
+	// до первого события climber стоит сразу под последним в таблице
+	want := []int{2, 2, 3, 2, 1}
+
+	if got := RankTimeline(events, "climber"); !reflect.DeepEqual(got, want) {
+		t.Errorf("RankTimeline = %v, want %v", got, want)
+	}
+}
+
+func TestTeamContributionLeaderboard(t *testing.T) {
+	team := func(id string) string {
+		return map[string]string{"player1": "red", "player3": "red", "player2": "blue", "a": "mixed", "b": "mixed", "c": "negative"}[id]
+	}
+
+	tests := []struct {
+		name   string
+		events []Event
+		want   map[string][]PlayerShare
+	}{
+		{
+			name: "multi- and single-member teams",
+			events: []Event{
+				{ID: "e1", PlayerID: "player1", Score: 25},
+				{ID: "e2", PlayerID: "player2", Score: 50},
+				{ID: "e3", PlayerID: "player3", Score: 75},
+			},
+			want: map[string][]PlayerShare{
+				"red":  {{ID: "player3", Score: 75, Share: 75}, {ID: "player1", Score: 25, Share: 25}},
+				"blue": {{ID: "player2", Score: 50, Share: 100}},
+			},
+		},
+		{
+			name:   "negative team total",
+			events: []Event{{ID: "e1", PlayerID: "a", Score: 5}, {ID: "e2", PlayerID: "b", Score: -15}},
+			want:   map[string][]PlayerShare{"mixed": {{ID: "a", Score: 5, Share: 100}, {ID: "b", Score: -15, Share: 0}}},
+		},
+		{
+			name:   "no positive totals",
+			events: []Event{{ID: "e1", PlayerID: "c", Score: -5}},
+			want:   map[string][]PlayerShare{"negative": {{ID: "c", Score: -5, Share: 0}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TeamContributionLeaderboard(tt.events, team); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TeamContributionLeaderboard = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanLeaderboardMatchesBatch(t *testing.T) {
+	events := manyEvents(10, 4)
+	events = append(events, events[:7]...)
+	events = append(events, Event{PlayerID: "p00", Score: 3}, Event{PlayerID: "p00", Score: 3})
+
+	var snapshots [][]Player
+	for snapshot := range ScanLeaderboard(context.Background(), shard(events)) {
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if want := len(DedupeEvents(events)); len(snapshots) != want {
+		t.Fatalf("got %d snapshots, want %d", len(snapshots), want)
+	}
+	if got, want := snapshots[len(snapshots)-1], updateLeaderboardCorecursive(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("final snapshot = %v, want %v", got, want)
+	}
+}
+
+func TestScoreGini(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []Player
+		want    float64
+	}{
+		{name: "uniform", players: []Player{{Score: 10}, {Score: 10}, {Score: 10}}, want: 0},
+		// n = 4, сумма 100, взвешенная сумма 400: 2*400/(4*100) - 5/4
+		{name: "skewed", players: []Player{{Score: 0}, {Score: 0}, {Score: 0}, {Score: 100}}, want: 0.75},
+		{name: "negative counts as zero", players: []Player{{Score: 100}, {Score: -50}, {Score: 0}, {Score: 0}}, want: 0.75},
+		{name: "all zeros", players: []Player{{Score: 0}, {Score: 0}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ScoreGini(tt.players)
+			if !ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ScoreGini = %v, %v, want %v, true", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstLastByPlayer(t *testing.T) {
+	events := []Event{
+		{PlayerID: "many"}, {PlayerID: "once"}, {PlayerID: "many"}, {PlayerID: "other"}, {PlayerID: "many"},
+	}
+	want := map[string][2]int{"many": {0, 4}, "once": {1, 1}, "other": {3, 3}}
+
+	if got := FirstLastByPlayer(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("FirstLastByPlayer = %v, want %v", got, want)
+	}
+}
+
+func TestLeaderboardVolatility(t *testing.T) {
+	before := []Player{{ID: "a", Rank: 1}, {ID: "b", Rank: 2}, {ID: "c", Rank: 3}, {ID: "d", Rank: 4}}
+	reversed := []Player{{ID: "d", Rank: 1}, {ID: "c", Rank: 2}, {ID: "b", Rank: 3}, {ID: "a", Rank: 4}}
+
+	if got := LeaderboardVolatility(before, before); got != 0 {
+		t.Errorf("identical orderings: LeaderboardVolatility = %v, want 0", got)
+	}
+	if got := LeaderboardVolatility(before, reversed); got != 1 {
+		t.Errorf("full reversal: LeaderboardVolatility = %v, want 1", got)
+	}
+}
+
+func TestPrefixSums(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   []int
+	}{
+		{name: "empty", events: nil, want: []int{}},
+		{name: "single event", events: []Event{{PlayerID: "a", Score: 7}}, want: []int{7}},
+		// повторная доставка e4 отбрасывается: 5 событий на входе, 4 суммы
+		{name: "sample events with a redelivery", events: sampleEvents(), want: []int{100, 150, 120, 320}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PrefixSums(tt.events); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PrefixSums = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservoirSampleFixedSeed(t *testing.T) {
+	events := manyEvents(10, 3)
+
+	// тот же Algorithm R по срезу с тем же зерном
+	rng := rand.New(rand.NewSource(42))
+	want := append([]Event(nil), events[:3]...)
+	for i := 3; i < len(events); i++ {
+		if j := rng.Intn(i + 1); j < 3 {
+			want[j] = events[i]
+		}
+	}
+
+	if got := ReservoirSample(shard(events), 3, rand.New(rand.NewSource(42))); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReservoirSample = %v, want %v", got, want)
+	}
+}
+
+func TestReservoirSampleShortStream(t *testing.T) {
+	events := sampleEvents()
+	if got := ReservoirSample(shard(events), 10, rand.New(rand.NewSource(1))); !reflect.DeepEqual(got, events) {
+		t.Errorf("ReservoirSample = %v, want the whole stream %v", got, events)
+	}
+	if got := ReservoirSample(shard(events), 0, rand.New(rand.NewSource(1))); got != nil {
+		t.Errorf("ReservoirSample k=0 = %v, want nil", got)
+	}
+}
+
+func TestSmallLeaderboardMatchesCorecursive(t *testing.T) {
+	below := append(manyEvents(5, 3), Event{PlayerID: "p01", Score: 4}, Event{PlayerID: "p01", Score: 4}, Event{ID: "e0-0", PlayerID: "p00", Score: 9})
+	at := manyEvents(8, smallLeaderboardThreshold/8)
+
+	tests := []struct {
+		name   string
+		events []Event
+	}{
+		{name: "empty", events: nil},
+		{name: "sample events", events: sampleEvents()},
+		{name: "below the threshold with repeats", events: below},
+		{name: "at the threshold", events: at},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SmallLeaderboard(tt.events)
+			if want := updateLeaderboardCorecursive(tt.events); !reflect.DeepEqual(got, want) {
+				t.Errorf("SmallLeaderboard = %v, want %v", got, want)
+			}
+		})
+	}
+	if len(below) >= smallLeaderboardThreshold || len(at) != smallLeaderboardThreshold {
+		t.Fatalf("fixture sizes %d and %d do not straddle the threshold %d", len(below), len(at), smallLeaderboardThreshold)
+	}
+}
+
+func BenchmarkSmallLeaderboard(b *testing.B) {
+	events := manyEvents(6, 4)
+	b.Run("SmallLeaderboard", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			SmallLeaderboard(events)
+		}
+	})
+	b.Run("updateLeaderboardCorecursive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			updateLeaderboardCorecursive(events)
+		}
+	})
+}
+
+func TestHasDuplicatePlayers(t *testing.T) {
+	if !HasDuplicatePlayers(sampleEvents()) {
+		t.Error("HasDuplicatePlayers with repeated players = false, want true")
+	}
+	unique := []Event{{PlayerID: "a"}, {PlayerID: "b"}, {PlayerID: "c"}}
+	if HasDuplicatePlayers(unique) {
+		t.Error("HasDuplicatePlayers with unique players = true, want false")
+	}
+}
+
+func TestLeaderboardApplyBatchMatchesRecompute(t *testing.T) {
+	first := []Event{
+		{ID: "e1", PlayerID: "a", Score: 10},
+		{ID: "e2", PlayerID: "b", Score: 20},
+		{ID: "e3", PlayerID: "a", Score: 5},
+	}
+	second := []Event{
+		{ID: "e2", PlayerID: "b", Score: 20},
+		{ID: "e4", PlayerID: "c", Score: 40},
+		{PlayerID: "a", Score: 1},
+	}
+
+	lb := NewLeaderboard()
+	if got, want := lb.ApplyBatch(first), updateLeaderboardCorecursive(first); !reflect.DeepEqual(got, want) {
+		t.Fatalf("first ApplyBatch = %v, want %v", got, want)
+	}
+	got := lb.ApplyBatch(second)
+	if want := updateLeaderboardCorecursive(append(append([]Event(nil), first...), second...)); !reflect.DeepEqual(got, want) {
+		t.Errorf("second ApplyBatch = %v, want %v", got, want)
+	}
+}
+
+func TestLeaderboardFor(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []string
+		want []Player
+	}{
+		{
+			name: "subset with events",
+			ids:  []string{"player2", "player1"},
+			want: []Player{{ID: "player1", Score: 70, Rank: 1, Events: 2}, {ID: "player2", Score: 50, Rank: 2, Events: 1}},
+		},
+		{
+			name: "subset without events",
+			ids:  []string{"friend"},
+			want: []Player{{ID: "friend", Rank: 1}},
+		},
+		{name: "empty subset", ids: nil, want: []Player{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LeaderboardFor(sampleEvents(), tt.ids); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LeaderboardFor(%v) = %v, want %v", tt.ids, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasTiedWinner(t *testing.T) {
+	tests := []struct {
+		name     string
+		players  []Player
+		wantTied bool
+		wantIDs  []string
+	}{
+		{
+			name:    "single winner",
+			players: updateLeaderboardCorecursive(sampleEvents()),
+			wantIDs: []string{"player3"},
+		},
+		{
+			name:     "two-way tie",
+			players:  updateLeaderboardCorecursive(append(sampleEvents(), Event{ID: "e5", PlayerID: "player2", Score: 150})),
+			wantTied: true,
+			wantIDs:  []string{"player2", "player3"},
+		},
+		{name: "empty", players: nil, wantIDs: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tied, ids := HasTiedWinner(tt.players)
+			if tied != tt.wantTied || !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("HasTiedWinner = %v, %v, want %v, %v", tied, ids, tt.wantTied, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestInterleaveEvents(t *testing.T) {
+	a := []Event{{ID: "a1"}, {ID: "a2"}}
+	b := []Event{{ID: "b1"}, {ID: "b2"}}
+	c := []Event{{ID: "c1"}, {ID: "c2"}, {ID: "c3"}, {ID: "c4"}}
+
+	tests := []struct {
+		name    string
+		streams [][]Event
+		want    []string
+	}{
+		{name: "equal-length streams", streams: [][]Event{a, b}, want: []string{"a1", "b1", "a2", "b2"}},
+		{name: "unequal-length streams", streams: [][]Event{c, nil, a}, want: []string{"c1", "a1", "c2", "a2", "c3", "c4"}},
+		{name: "no streams", streams: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range InterleaveEvents(tt.streams...) {
+				got = append(got, e.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("InterleaveEvents = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderboardHash(t *testing.T) {
+	standings := updateLeaderboardCorecursive(sampleEvents())
+	if LeaderboardHash(standings) != LeaderboardHash(SmallLeaderboard(sampleEvents())) {
+		t.Error("equal standings hash differently")
+	}
+
+	swapped := append([]Player(nil), standings...)
+	swapped[1], swapped[2] = swapped[2], swapped[1]
+	if LeaderboardHash(swapped) == LeaderboardHash(standings) {
+		t.Error("swapping two rows did not change the hash")
+	}
+}